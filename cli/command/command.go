@@ -0,0 +1,37 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type Command struct {
+	Description string
+	Run         func(ctx context.Context, args []string) error
+}
+
+var Commands = map[string]Command{
+	"evaluate": {
+		Description: "measure the recall and the latency of the approximate search of a worker",
+		Run:         runEvaluate,
+	},
+	"import": {
+		Description: "import a fvecs, bvecs, ivecs, npy, npz or ndjson dataset into new shards",
+		Run:         runImport,
+	},
+	"export": {
+		Description: "export a shard to a fvecs, bvecs, ivecs, npy, npz or ndjson dataset",
+		Run:         runExport,
+	},
+}
+
+func dial(address string) (*grpc.ClientConn, error) {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+
+	return conn, nil
+}
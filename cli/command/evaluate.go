@@ -0,0 +1,74 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	shared_proto_build_collection "github.com/danielealbano/svdb/shared/proto/build/collection"
+	"os"
+)
+
+func runEvaluate(ctx context.Context, args []string) error {
+	var queries []*shared_proto_build_collection.Vector
+
+	flags := flag.NewFlagSet("evaluate", flag.ContinueOnError)
+	address := flags.String("address", "127.0.0.1:3000", "address of the worker")
+	k := flags.Uint("k", 10, "number of neighbors compared for the recall@k")
+	sample := flags.Uint("sample", 100, "number of vectors of the shard sampled as queries")
+	queriesPath := flags.String("queries", "", "JSON file with an array of queries, used instead of the samples")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *queriesPath != "" {
+		data, err := os.ReadFile(*queriesPath)
+		if err != nil {
+			return fmt.Errorf("failed to read queries: %w", err)
+		}
+
+		var values [][]float32
+		if err = json.Unmarshal(data, &values); err != nil {
+			return fmt.Errorf("failed to parse queries: %w", err)
+		}
+
+		queries = make([]*shared_proto_build_collection.Vector, len(values))
+		for i, v := range values {
+			queries[i] = &shared_proto_build_collection.Vector{Values: v}
+		}
+	}
+
+	conn, err := dial(*address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := shared_proto_build_collection.NewCollectionClient(conn).Evaluate(
+		ctx,
+		&shared_proto_build_collection.EvaluateRequest{
+			Queries: queries,
+			Sample:  uint32(*sample),
+			K:       uint32(*k),
+		})
+	if err != nil {
+		return fmt.Errorf("failed to evaluate: %w", err)
+	}
+
+	settings := res.Settings
+	fmt.Printf("settings:  dimensions=%d metric=%s quantization=%s multi=%t length=%d\n",
+		settings.Dimensions, settings.Metric, settings.Quantization, settings.Multi, settings.Length)
+	fmt.Printf("           connectivity=%d expansion_add=%d expansion_search=%d\n",
+		settings.Connectivity, settings.ExpansionAdd, settings.ExpansionSearch)
+	fmt.Printf("queries:   %d\n", res.Queries)
+	fmt.Printf("recall@%d: %.4f\n", res.K, res.Recall)
+	printLatency("approximate", res.ApproximateLatency)
+	printLatency("exact", res.ExactLatency)
+
+	return nil
+}
+
+func printLatency(name string, latency *shared_proto_build_collection.LatencyPercentiles) {
+	fmt.Printf("%-12s p50=%dus p90=%dus p99=%dus max=%dus\n",
+		name+":", latency.P50Micros, latency.P90Micros, latency.P99Micros, latency.MaxMicros)
+}
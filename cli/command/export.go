@@ -0,0 +1,105 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/danielealbano/svdb/shared/collection"
+	"github.com/danielealbano/svdb/shared/dataset"
+	"os"
+)
+
+func runExport(_ context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	shard := flags.String("shard", "", "path of the shard to export")
+	output := flags.String("output", "", "dataset to write")
+	format := flags.String("format", "", "format of the dataset (fvecs, bvecs, ivecs, npy, npz, ndjson), from the extension if empty")
+	metadata := flags.Bool("metadata", true, "export the metadata and the text, only ndjson stores them")
+	dimensions := flags.Uint("dimensions", 0, "dimensions of the vectors, if the shard has no manifest")
+	metric := flags.String("metric", "cosine", "metric of the collection, if the shard has no manifest")
+	quantization := flags.String("quantization", "f32", "quantization of the collection, if the shard has no manifest")
+	multi := flags.Bool("multi", false, "the shard stores multiple vectors per key, if the shard has no manifest")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *shard == "" || *output == "" {
+		return errors.New("-shard and -output are required")
+	}
+
+	datasetFormat, err := parseDatasetFormat(*format, *output)
+	if err != nil {
+		return err
+	}
+
+	// Complete the save interrupted by a crash, if any, so the manifest read matches the shard
+	err = shared_collection.RecoverShard(*shard)
+	if err != nil {
+		return err
+	}
+
+	config := shared_collection.NewCollectionConfig()
+	manifest, err := shared_collection.LoadManifest(*shard)
+	switch {
+	case err == nil:
+		config.Dimensions = manifest.Dimensions
+		config.Multi = manifest.Multi
+		config.Rescore = manifest.Rescore
+		config.MaxSize = manifest.MaxSize
+		config.Connectivity = manifest.Connectivity
+		*metric = manifest.Metric
+		*quantization = manifest.Quantization
+	case errors.Is(err, os.ErrNotExist) && *dimensions > 0:
+		config.Dimensions = *dimensions
+		config.Multi = *multi
+	case errors.Is(err, os.ErrNotExist):
+		return errors.New("the shard has no manifest, -dimensions is required")
+	default:
+		return err
+	}
+
+	if config.Metric, err = shared_collection.ParseMetric(*metric); err != nil {
+		return err
+	}
+
+	if config.Quantization, err = shared_collection.ParseQuantization(*quantization); err != nil {
+		return err
+	}
+
+	coll, err := shared_collection.NewCollection(config)
+	if err != nil {
+		return err
+	}
+	defer coll.Destroy()
+
+	// The shard is only read, memory-mapping it avoids loading it in memory
+	err = coll.View(*shard)
+	if err != nil {
+		return fmt.Errorf("failed to load shard %s: %w", *shard, err)
+	}
+
+	writer, err := shared_dataset.Create(*output, datasetFormat)
+	if err != nil {
+		return err
+	}
+
+	records, err := shared_dataset.Export(coll, writer, shared_dataset.ExportOptions{
+		IncludeMetadata: *metadata && datasetFormat.HasMetadata(),
+		Progress: func(records uint64) {
+			fmt.Fprintf(os.Stderr, "\rexported %d vectors", records)
+		},
+	})
+	fmt.Fprintln(os.Stderr)
+	err = errors.Join(err, writer.Close())
+	if err != nil {
+		return fmt.Errorf("failed to export %s: %w", *shard, err)
+	}
+
+	fmt.Printf("exported %d vectors to %s\n", records, *output)
+	if !datasetFormat.HasKeys() {
+		fmt.Printf("the keys are not stored in the %s format, the vectors are sorted by key\n", datasetFormat)
+	}
+
+	return nil
+}
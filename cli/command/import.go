@@ -0,0 +1,133 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/c2h5oh/datasize"
+	"github.com/danielealbano/svdb/shared/collection"
+	"github.com/danielealbano/svdb/shared/dataset"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func runImport(_ context.Context, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	input := flags.String("input", "", "dataset to import")
+	format := flags.String("format", "", "format of the dataset (fvecs, bvecs, ivecs, npy, npz, ndjson), from the extension if empty")
+	shard := flags.String("shard", "", "base path of the shards, the shards are saved to <shard>-0000, <shard>-0001, etc.")
+	dimensions := flags.Uint("dimensions", 0, "dimensions of the vectors")
+	metric := flags.String("metric", "cosine", "metric of the collection")
+	quantization := flags.String("quantization", "f32", "quantization of the collection")
+	multi := flags.Bool("multi", false, "store multiple vectors per key")
+	rescore := flags.Bool("rescore", false, "store the full-precision vectors to rescore the searches")
+	validation := flags.String("validation", "", "validation of the vectors (none, finite, finite-non-zero), from the metric if empty")
+	maxSize := flags.String("max-size", "1GB", "max size of a shard, a new shard is started when it's reached")
+	mode := flags.String("mode", "insert", "write mode (insert, upsert, skip-if-exists)")
+	firstKey := flags.Uint64("first-key", 0, "key of the first vector for the formats without keys")
+	batch := flags.Int("batch", shared_dataset.ImportDefaultBatchSize, "number of vectors added at once")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *input == "" || *shard == "" || *dimensions == 0 {
+		return errors.New("-input, -shard and -dimensions are required")
+	}
+
+	datasetFormat, err := parseDatasetFormat(*format, *input)
+	if err != nil {
+		return err
+	}
+
+	config := shared_collection.NewCollectionConfig()
+	config.Dimensions = *dimensions
+	config.Multi = *multi
+	config.Rescore = *rescore
+	config.TempDir = filepath.Dir(*shard)
+
+	if config.Metric, err = shared_collection.ParseMetric(*metric); err != nil {
+		return err
+	}
+
+	if config.Quantization, err = shared_collection.ParseQuantization(*quantization); err != nil {
+		return err
+	}
+
+	config.VectorValidation = shared_collection.DefaultVectorValidation(config.Metric)
+	if *validation != "" {
+		if config.VectorValidation, err = shared_collection.ParseVectorValidation(*validation); err != nil {
+			return err
+		}
+	}
+
+	size, err := datasize.ParseString(*maxSize)
+	if err != nil || size == 0 {
+		return fmt.Errorf("invalid max size: %s", *maxSize)
+	}
+	config.MaxSize = uint(size)
+
+	writeMode, err := parseWriteMode(*mode)
+	if err != nil {
+		return err
+	}
+
+	reader, err := shared_dataset.Open(
+		*input,
+		datasetFormat,
+		shared_dataset.ReaderOptions{FirstKey: shared_collection.Key(*firstKey)})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	progress, err := shared_dataset.Import(reader, shared_dataset.ImportOptions{
+		Config:    config,
+		BasePath:  *shard,
+		Mode:      writeMode,
+		BatchSize: *batch,
+		Progress: func(progress shared_dataset.ImportProgress) {
+			fmt.Fprintf(os.Stderr, "\rread %d vectors, inserted %d, shards %d",
+				progress.Records, progress.Inserted, len(progress.Shards)+1)
+		},
+	})
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %w", *input, err)
+	}
+
+	fmt.Printf("imported %d of %d vectors into %d shards:\n", progress.Inserted, progress.Records, len(progress.Shards))
+	for _, path := range progress.Shards {
+		fmt.Printf("  %s\n", path)
+	}
+
+	return nil
+}
+
+// parseDatasetFormat parses the format, or gets it from the extension of the path if empty
+func parseDatasetFormat(format string, path string) (shared_dataset.Format, error) {
+	if format != "" {
+		return shared_dataset.ParseFormat(format)
+	}
+
+	datasetFormat, err := shared_dataset.FormatFromPath(path)
+	if err != nil {
+		return 0, fmt.Errorf("unknown format of %s, use -format: %w", path, err)
+	}
+
+	return datasetFormat, nil
+}
+
+func parseWriteMode(mode string) (shared_collection.WriteMode, error) {
+	switch strings.ToLower(mode) {
+	case "insert":
+		return shared_collection.WriteModeInsert, nil
+	case "upsert":
+		return shared_collection.WriteModeUpsert, nil
+	case "skip-if-exists":
+		return shared_collection.WriteModeSkipIfExists, nil
+	default:
+		return 0, fmt.Errorf("invalid write mode: %s", mode)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/danielealbano/svdb/cli/command"
+	shared_support "github.com/danielealbano/svdb/shared/support"
+	"maps"
+	"os"
+	"slices"
+)
+
+var (
+	version       = ""
+	commit        = ""
+	buildDate     = ""
+	builtBy       = ""
+	goLangVersion = ""
+)
+
+// Exit statuses of the process, a missing or unknown command is a usage error
+const (
+	exitSuccess    = 0
+	exitFailure    = 1
+	exitUsageError = 2
+)
+
+// exitCode is set by mainReal and used once GenericMain has returned, so its deferred cleanups run before exiting
+var exitCode = exitSuccess
+
+func main() {
+	shared_support.GenericMain(
+		mainReal,
+		version,
+		commit,
+		buildDate,
+		builtBy,
+		goLangVersion)
+
+	os.Exit(exitCode)
+}
+
+func mainReal() {
+	exitCode = run()
+}
+
+func run() int {
+	if len(os.Args) < 2 {
+		printUsage()
+		return exitUsageError
+	}
+
+	cmd, found := command.Commands[os.Args[1]]
+	if !found {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", os.Args[1])
+		printUsage()
+		return exitUsageError
+	}
+
+	// The errors are printed out directly as the logger is asynchronous and the messages might be lost on exit, the
+	// flags package has already printed the usage of the command if it has been requested
+	err := cmd.Run(shared_support.StopSignal.Context, os.Args[2:])
+	if errors.Is(err, flag.ErrHelp) {
+		return exitSuccess
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitFailure
+	}
+
+	return exitSuccess
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [arguments]\n\ncommands:\n", shared_support.GetExecutableName())
+	for _, name := range slices.Sorted(maps.Keys(command.Commands)) {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, command.Commands[name].Description)
+	}
+}
@@ -17,6 +17,9 @@ type Config struct {
 	CollectionQuantization     string `env:"COLLECTION_QUANTIZATION" envDefault:"F32"`
 	CollectionMetric           string `env:"COLLECTION_METRIC" envDefault:"Cosine"`
 	CollectionVectorDimensions uint   `env:"COLLECTION_VECTOR_DIMENSIONS" envDefault:"128"`
+	CollectionMulti            bool   `env:"COLLECTION_MULTI" envDefault:"false"`
+	CollectionRescore          bool   `env:"COLLECTION_RESCORE" envDefault:"false"`
+	CollectionVectorValidation string `env:"COLLECTION_VECTOR_VALIDATION"`
 	CollectionPath             string `env:"COLLECTION_PATH"`
 	ShardMaxSize               string `env:"SHARD_MAX_SIZE" envDefault:"1GB"`
 	ShardAutoSync              bool   `env:"SHARD_AUTO_SYNC" envDefault:"false"`
@@ -77,6 +80,13 @@ func ValidateConfig(config *Config) error {
 		return fmt.Errorf("invalid collection metric: %s", config.CollectionMetric)
 	}
 
+	// If not set the validation depends on the metric of the collection
+	if config.CollectionVectorValidation != "" {
+		if _, err = shared_collection.ParseVectorValidation(config.CollectionVectorValidation); err != nil {
+			return fmt.Errorf("invalid collection vector validation: %s", config.CollectionVectorValidation)
+		}
+	}
+
 	maxSize, err = ParseShardMaxSize(config.ShardMaxSize)
 	if err != nil {
 		return fmt.Errorf("failed to parse the shard max size: %w", err)
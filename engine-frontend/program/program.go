@@ -34,6 +34,23 @@ func (p *Program) updateLoggerLevel() {
 	shared_support.Logger().Level = log.ParseLevel(p.config.LogLevel)
 }
 
+func (p *Program) initializeCollectionConfig() *shared_collection.CollectionConfig {
+	collectionConfig := shared_collection.NewCollectionConfig()
+	collectionConfig.MaxSize, _ = config.ParseShardMaxSize(p.config.ShardMaxSize)
+	collectionConfig.Dimensions = p.config.CollectionVectorDimensions
+	collectionConfig.Quantization, _ = shared_collection.ParseQuantization(p.config.CollectionQuantization)
+	collectionConfig.Metric, _ = shared_collection.ParseMetric(p.config.CollectionMetric)
+	collectionConfig.Multi = p.config.CollectionMulti
+	collectionConfig.Rescore = p.config.CollectionRescore
+	collectionConfig.VectorValidation = shared_collection.DefaultVectorValidation(collectionConfig.Metric)
+	if p.config.CollectionVectorValidation != "" {
+		collectionConfig.VectorValidation, _ = shared_collection.ParseVectorValidation(
+			p.config.CollectionVectorValidation)
+	}
+
+	return collectionConfig
+}
+
 func (p *Program) setupGrpcServer() (*shared_grpc_server.GrpcServer, error) {
 	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", p.config.Host, p.config.Port))
 	if err != nil {
@@ -63,6 +80,9 @@ func (p *Program) Start() {
 	// Update the logger level
 	p.updateLoggerLevel()
 
+	// Setup the collection configuration
+	p.collectionConfig = p.initializeCollectionConfig()
+
 	// Check if the path exists
 	if _, err = os.Stat(p.config.CollectionPath); os.IsNotExist(err) {
 		// TODO: Initialize the collection
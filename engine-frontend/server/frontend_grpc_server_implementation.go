@@ -7,6 +7,7 @@ import (
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"math"
 )
 
 type frontendGrpcServerImplementation struct {
@@ -18,6 +19,72 @@ func vectorToPB(v []float32) *shared_proto_build_frontend.Vector {
 	return &shared_proto_build_frontend.Vector{Values: v}
 }
 
+// validateRadiusSearch checks the arguments of a radius search, a maxResults of 0 means the max allowed
+func validateRadiusSearch(maxDistance float32, maxResults uint32) error {
+	if math.IsNaN(float64(maxDistance)) {
+		return status.Errorf(codes.InvalidArgument, "max distance must be a number")
+	}
+
+	if maxResults > shared_collection.RadiusSearchMaxResults {
+		return status.Errorf(
+			codes.InvalidArgument,
+			"max results must be less than or equal to %d",
+			shared_collection.RadiusSearchMaxResults)
+	}
+
+	return nil
+}
+
+// validateHybridSearch checks the arguments of a hybrid search, a nil textWeight means the default weight
+func validateHybridSearch(fusion shared_proto_build_frontend.FusionStrategy, textWeight *float32) error {
+	if !shared_collection.FusionStrategy(fusion).IsValid() {
+		return status.Errorf(codes.InvalidArgument, "invalid fusion strategy: %d", fusion)
+	}
+
+	if textWeight != nil && !(*textWeight >= 0 && *textWeight <= 1) {
+		return status.Errorf(codes.InvalidArgument, "text weight must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// validateRescore checks that the collection stores the full-precision vectors if the search is rescored
+func (s *frontendGrpcServerImplementation) validateRescore(rescoreFactor uint32) error {
+	if rescoreFactor == 0 {
+		return nil
+	}
+
+	if rescoreFactor > shared_collection.RescoreMaxFactor {
+		return status.Errorf(
+			codes.InvalidArgument,
+			"rescore factor must be less than or equal to %d",
+			shared_collection.RescoreMaxFactor)
+	}
+
+	if !s.collectionConfig.Rescore {
+		return status.Errorf(codes.FailedPrecondition, "%v", shared_collection.ErrRescoreDisabled)
+	}
+
+	return nil
+}
+
+// validateVectors checks the values of the vectors with the validation configured for the collection, kind names them
+// in the errors (e.g. vector or query)
+func (s *frontendGrpcServerImplementation) validateVectors(
+	kind string,
+	vectors []*shared_proto_build_frontend.Vector) error {
+	values := make([]shared_collection.Vector, len(vectors))
+	for i, v := range vectors {
+		values[i] = v.GetValues()
+	}
+
+	if err := s.collectionConfig.VectorValidation.Validate(kind, values); err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	return nil
+}
+
 func RegisterFrontendGrpcServerImplementation(
 	server *shared_grpc_server.GrpcServer,
 	collectionConfig *shared_collection.CollectionConfig) {
@@ -39,41 +106,162 @@ func (s *frontendGrpcServerImplementation) Search(
 			status.Errorf(
 				codes.InvalidArgument,
 				"expected %d dimensions, got %d",
-				len(req.Query.Values),
-				s.collectionConfig.Dimensions)
+				s.collectionConfig.Dimensions,
+				len(req.Query.Values))
+	}
+
+	if err := s.validateVectors("query", []*shared_proto_build_frontend.Vector{req.Query}); err != nil {
+		return &shared_proto_build_frontend.SearchResponse{}, err
+	}
+
+	if req.KeysFilter != nil && req.KeysFilter.Mode != shared_proto_build_frontend.KeysFilterMode_KEYS_FILTER_MODE_ALLOW &&
+		req.KeysFilter.Mode != shared_proto_build_frontend.KeysFilterMode_KEYS_FILTER_MODE_DENY {
+		return &shared_proto_build_frontend.SearchResponse{},
+			status.Errorf(codes.InvalidArgument, "invalid keys filter mode: %d", req.KeysFilter.Mode)
+	}
+
+	if req.Filter != "" {
+		if _, err := shared_collection.ParseFilter(req.Filter); err != nil {
+			return &shared_proto_build_frontend.SearchResponse{}, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+	}
+
+	if req.MaxDistance != nil {
+		if err := validateRadiusSearch(*req.MaxDistance, req.MaxResults); err != nil {
+			return &shared_proto_build_frontend.SearchResponse{}, err
+		}
+	} else if req.Limit <= 0 {
+		return &shared_proto_build_frontend.SearchResponse{},
+			status.Errorf(codes.InvalidArgument, "limit must be greater than 0")
+	}
+
+	if req.TextQuery != "" {
+		if err := validateHybridSearch(req.Fusion, req.TextWeight); err != nil {
+			return &shared_proto_build_frontend.SearchResponse{}, err
+		}
 	}
 
-	//keys, distances, err := s.collection.Search(req.Query.Values, req.Limit)
+	if err := s.validateRescore(req.RescoreFactor); err != nil {
+		return &shared_proto_build_frontend.SearchResponse{}, err
+	}
+
+	//result, err := s.collection.Search(req.Query.Values, req.Limit, searchOptionsFromPB(req))
 	//if err != nil {
 	//	return nil, err
 	//}
 	//
-	//return &shared_proto_build_frontend.SearchResponse{
-	//	Keys:      *(*[]uint64)(unsafe.Pointer(&keys)),
-	//	Distances: distances,
-	//}, nil
+	//return searchResultToPB(result), nil
+}
+
+func (s *frontendGrpcServerImplementation) SearchMulti(
+	_ context.Context,
+	req *shared_proto_build_frontend.SearchMultiRequest) (*shared_proto_build_frontend.SearchMultiResponse, error) {
+	if req == nil || len(req.Queries) == 0 {
+		return &shared_proto_build_frontend.SearchMultiResponse{},
+			status.Errorf(codes.InvalidArgument, "request empty or missing arguments")
+	}
+
+	limits := req.Limits
+	if req.MaxDistance != nil {
+		if err := validateRadiusSearch(*req.MaxDistance, req.MaxResults); err != nil {
+			return &shared_proto_build_frontend.SearchMultiResponse{}, err
+		}
+
+		limits = []uint32{shared_collection.RadiusSearchMaxResults}
+	} else if len(limits) == 0 {
+		limits = []uint32{req.Limit}
+	} else if len(limits) != len(req.Queries) {
+		return &shared_proto_build_frontend.SearchMultiResponse{},
+			status.Errorf(codes.InvalidArgument, "queries and limits must have the same length")
+	}
+
+	for i, limit := range limits {
+		if limit <= 0 {
+			return &shared_proto_build_frontend.SearchMultiResponse{},
+				status.Errorf(codes.InvalidArgument, "limit %d must be greater than 0", i)
+		}
+	}
+
+	for i, q := range req.Queries {
+		if q == nil || len(q.Values) != int(s.collectionConfig.Dimensions) {
+			return &shared_proto_build_frontend.SearchMultiResponse{},
+				status.Errorf(
+					codes.InvalidArgument,
+					"query %d, expected %d dimensions, got %d",
+					i,
+					s.collectionConfig.Dimensions,
+					len(q.GetValues()))
+		}
+	}
+
+	if err := s.validateVectors("query", req.Queries); err != nil {
+		return &shared_proto_build_frontend.SearchMultiResponse{}, err
+	}
+
+	if req.KeysFilter != nil && req.KeysFilter.Mode != shared_proto_build_frontend.KeysFilterMode_KEYS_FILTER_MODE_ALLOW &&
+		req.KeysFilter.Mode != shared_proto_build_frontend.KeysFilterMode_KEYS_FILTER_MODE_DENY {
+		return &shared_proto_build_frontend.SearchMultiResponse{},
+			status.Errorf(codes.InvalidArgument, "invalid keys filter mode: %d", req.KeysFilter.Mode)
+	}
+
+	if req.Filter != "" {
+		if _, err := shared_collection.ParseFilter(req.Filter); err != nil {
+			return &shared_proto_build_frontend.SearchMultiResponse{}, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+	}
+
+	if req.TextQuery != "" {
+		if err := validateHybridSearch(req.Fusion, req.TextWeight); err != nil {
+			return &shared_proto_build_frontend.SearchMultiResponse{}, err
+		}
+	}
+
+	if err := s.validateRescore(req.RescoreFactor); err != nil {
+		return &shared_proto_build_frontend.SearchMultiResponse{}, err
+	}
+
+	//results, err := s.collection.SearchMulti(queries, limits, searchOptionsFromPB(req))
+	//if err != nil {
+	//	return nil, err
+	//}
+	//
+	//return searchResultsToPB(results), nil
 }
 
 func (s *frontendGrpcServerImplementation) Add(
 	_ context.Context,
-	req *shared_proto_build_frontend.AddRequest) (*shared_proto_build_frontend.Empty, error) {
+	req *shared_proto_build_frontend.AddRequest) (*shared_proto_build_frontend.AddResponse, error) {
 	if req == nil || req.Vector == nil {
-		return &shared_proto_build_frontend.Empty{},
+		return &shared_proto_build_frontend.AddResponse{},
 			status.Errorf(codes.InvalidArgument, "request empty or missing arguments")
 	}
 
 	if len(req.Vector.Values) != int(s.collectionConfig.Dimensions) {
-		return &shared_proto_build_frontend.Empty{},
+		return &shared_proto_build_frontend.AddResponse{},
 			status.Errorf(
 				codes.InvalidArgument,
 				"expected %d dimensions, got %d",
-				len(req.Vector.Values),
-				s.collectionConfig.Dimensions)
+				s.collectionConfig.Dimensions,
+				len(req.Vector.Values))
+	}
+
+	if err := s.validateVectors("vector", []*shared_proto_build_frontend.Vector{req.Vector}); err != nil {
+		return &shared_proto_build_frontend.AddResponse{}, err
+	}
+
+	if !shared_collection.WriteMode(req.Mode).IsValid() {
+		return &shared_proto_build_frontend.AddResponse{},
+			status.Errorf(codes.InvalidArgument, "invalid write mode: %d", req.Mode)
 	}
 
-	//_, isFull, err := s.collection.Add(shared_collection.Key(req.Key), req.Vector.Values)
+	//result, err := s.collection.Add(
+	//	shared_collection.Key(req.Key),
+	//	req.Vector.Values,
+	//	metadataFromPB(req.Metadata),
+	//	req.Text,
+	//	shared_collection.WriteMode(req.Mode))
 	//return &shared_proto_build_frontend.AddResponse{
-	//	ShardFull: isFull,
+	//	Outcome: shared_proto_build_frontend.AddOutcome(result.Outcomes[0]),
 	//}, err
 }
 
@@ -95,19 +283,33 @@ func (s *frontendGrpcServerImplementation) AddMulti(
 			status.Errorf(codes.InvalidArgument, "no data provided")
 	}
 
-	//vectors := make([][]float32, len(req.Vectors))
-	//for i, v := range req.Vectors {
-	//	if len(req.Vectors[0].Values) != int(s.collectionConfig.Dimensions) {
-	//		return &shared_proto_build_frontend.AddMultiResponse{},
-	//			status.Errorf(
-	//				codes.InvalidArgument,
-	//				"vector %d, expected %d dimensions, got %d",
-	//				i,
-	//				len(req.Vectors[0].Values),
-	//				s.collectionConfig.Dimensions)
-	//	}
-	//
-	//	vectors[i] = v.Values
+	if !shared_collection.WriteMode(req.Mode).IsValid() {
+		return &shared_proto_build_frontend.AddMultiResponse{},
+			status.Errorf(codes.InvalidArgument, "invalid write mode: %d", req.Mode)
+	}
+
+	vectors := make([]*shared_proto_build_frontend.Vector, len(req.GetRequests()))
+	for i, r := range req.GetRequests() {
+		if r.GetVector() == nil || len(r.Vector.Values) != int(s.collectionConfig.Dimensions) {
+			return &shared_proto_build_frontend.AddMultiResponse{},
+				status.Errorf(
+					codes.InvalidArgument,
+					"vector %d, expected %d dimensions, got %d",
+					i,
+					s.collectionConfig.Dimensions,
+					len(r.GetVector().GetValues()))
+		}
+
+		vectors[i] = r.Vector
+	}
+
+	if err := s.validateVectors("vector", vectors); err != nil {
+		return &shared_proto_build_frontend.AddMultiResponse{}, err
+	}
+
+	//values := make([][]float32, len(vectors))
+	//for i, v := range vectors {
+	//	values[i] = v.Values
 	//}
 	//
 	//inserted, isFull, err := s.collection.AddMulti(
@@ -145,17 +347,15 @@ func (s *frontendGrpcServerImplementation) Get(
 			status.Errorf(codes.InvalidArgument, "request empty or missing arguments")
 	}
 
-	if req.Count <= 0 {
-		return &shared_proto_build_frontend.GetResponse{},
-			status.Errorf(codes.InvalidArgument, "count must be greater than 0")
-	}
-
-	//vec, err := s.collection.Get(shared_collection.Key(req.Key), uint(req.Count))
+	//vectors, metadata, err := s.collection.Get(shared_collection.Key(req.Key))
 	//if err != nil {
 	//	return nil, err
 	//}
 	//
-	//return &shared_proto_build_frontend.GetResponse{Vector: vectorToPB(vec)}, nil
+	//return &shared_proto_build_frontend.GetResponse{
+	//	Vectors:  vectorsToPB(vectors),
+	//	Metadata: metadataToPB(metadata),
+	//}, nil
 }
 
 func (s *frontendGrpcServerImplementation) Has(
@@ -186,6 +386,25 @@ func (s *frontendGrpcServerImplementation) Delete(
 	//}, err
 }
 
+func (s *frontendGrpcServerImplementation) DeleteMulti(
+	_ context.Context,
+	req *shared_proto_build_frontend.DeleteMultiRequest) (*shared_proto_build_frontend.DeleteMultiResponse, error) {
+	if req == nil || len(req.Keys) == 0 {
+		return &shared_proto_build_frontend.DeleteMultiResponse{},
+			status.Errorf(codes.InvalidArgument, "request empty or missing arguments")
+	}
+
+	//result, err := s.collection.DeleteMulti(*(*[]shared_collection.Key)(unsafe.Pointer(&req.Keys)))
+	//if err != nil {
+	//	return &shared_proto_build_frontend.DeleteMultiResponse{}, err
+	//}
+	//
+	//return &shared_proto_build_frontend.DeleteMultiResponse{
+	//	Deleted:  result.Deleted,
+	//	Outcomes: *(*[]shared_proto_build_frontend.DeleteOutcome)(unsafe.Pointer(&result.Outcomes)),
+	//}, nil
+}
+
 func (s *frontendGrpcServerImplementation) Save(
 	_ context.Context,
 	_ *shared_proto_build_frontend.Empty) (*shared_proto_build_frontend.Empty, error) {
@@ -0,0 +1,80 @@
+package autocompact
+
+import (
+	"github.com/danielealbano/svdb/shared/collection"
+	"github.com/danielealbano/svdb/shared/support"
+	"time"
+)
+
+// AutoCompact periodically compacts the collection when the slots of the removed vectors not reused yet reach
+// freeRatio of the slots of the index, or when the shard is full and there is at least one. The compacted collection
+// is dirty, it's saved by the auto sync, if enabled, or when the worker shuts down.
+type AutoCompact struct {
+	collection *shared_collection.Collection
+	interval   time.Duration
+	freeRatio  float64
+	task       *shared_support.PeriodicTask
+}
+
+func New(collection *shared_collection.Collection, interval time.Duration, freeRatio float64) *AutoCompact {
+	a := &AutoCompact{
+		collection: collection,
+		interval:   interval,
+		freeRatio:  freeRatio,
+	}
+	a.task = shared_support.NewPeriodicTask(interval, a.check)
+
+	return a
+}
+
+func (a *AutoCompact) Start() {
+	a.task.Start()
+
+	shared_support.Logger().Info().Msgf(
+		"shard auto compaction enabled, checking every %s with a free ratio of %.2f", a.interval, a.freeRatio)
+}
+
+// Stop waits for the compaction in progress, if any, to complete
+func (a *AutoCompact) Stop() {
+	a.task.Stop()
+}
+
+func (a *AutoCompact) check() {
+	if a.shouldCompact() {
+		a.compact()
+	}
+}
+
+func (a *AutoCompact) shouldCompact() bool {
+	free := a.collection.FreeSlots()
+	if free == 0 {
+		return false
+	}
+
+	if a.collection.IsFull() {
+		return true
+	}
+
+	length, err := a.collection.Length()
+	if err != nil {
+		return false
+	}
+
+	return float64(free) >= a.freeRatio*float64(uint64(length)+free)
+}
+
+func (a *AutoCompact) compact() {
+	start := time.Now()
+	result, err := a.collection.Compact()
+	if err != nil {
+		shared_support.Logger().Error().Msgf("shard auto compaction failed: %v", err)
+		return
+	}
+
+	shared_support.Logger().Info().Msgf(
+		"shard auto compacted in %s, reclaimed %d bytes, size %d bytes, full %t",
+		time.Since(start),
+		result.Reclaimed,
+		result.SizeAfter,
+		result.IsFull)
+}
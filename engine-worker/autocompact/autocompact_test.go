@@ -0,0 +1,88 @@
+package autocompact
+
+import (
+	"github.com/danielealbano/svdb/shared/collection"
+	"testing"
+	"time"
+)
+
+// newTestCollection returns a 3 dimensions collection with keys from 1 to count, or filled if count is 0, and the
+// odd keys up to remove deleted
+func newTestCollection(t *testing.T, count int, remove int) *shared_collection.Collection {
+	t.Helper()
+
+	config := shared_collection.NewCollectionConfig()
+	config.Dimensions = 3
+	config.MaxSize = 64 << 10
+	config.Metric = shared_collection.L2sq
+	config.VectorValidation = shared_collection.DefaultVectorValidation(shared_collection.L2sq)
+	config.TempDir = t.TempDir()
+
+	c, err := shared_collection.NewCollection(config)
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Destroy() })
+
+	for key := 1; (count == 0 && !c.IsFull()) || key <= count; key++ {
+		vector := shared_collection.Vector{float32(key), 0, 0}
+		if _, err := c.Add(shared_collection.Key(key), vector, nil, "", shared_collection.WriteModeInsert); err != nil {
+			t.Fatalf("failed to add key %d: %v", key, err)
+		}
+	}
+
+	for key := 1; key <= remove; key += 2 {
+		if err := c.Delete(shared_collection.Key(key)); err != nil {
+			t.Fatalf("failed to delete key %d: %v", key, err)
+		}
+	}
+
+	return c
+}
+
+func TestShouldCompact(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int
+		remove    int
+		freeRatio float64
+		expected  bool
+	}{
+		{name: "no free slots", count: 10, remove: 0, freeRatio: 0, expected: false},
+		{name: "below the free ratio", count: 10, remove: 2, freeRatio: 0.2, expected: false},
+		{name: "at the free ratio", count: 10, remove: 4, freeRatio: 0.2, expected: true},
+		{name: "above the free ratio", count: 10, remove: 10, freeRatio: 0.2, expected: true},
+		{name: "full with one free slot", count: 0, remove: 1, freeRatio: 1, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestCollection(t, test.count, test.remove)
+
+			if actual := New(c, time.Hour, test.freeRatio).shouldCompact(); actual != test.expected {
+				t.Errorf("expected %t, got %t with %d free slots", test.expected, actual, c.FreeSlots())
+			}
+		})
+	}
+}
+
+func TestAutoCompact(t *testing.T) {
+	c := newTestCollection(t, 0, 40)
+	if !c.IsFull() || c.FreeSlots() != 20 {
+		t.Fatalf("expected 20 free slots in a full shard, got %d, full %t", c.FreeSlots(), c.IsFull())
+	}
+
+	a := New(c, 10*time.Millisecond, 0.5)
+	a.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.FreeSlots() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.Stop()
+
+	if c.FreeSlots() != 0 || c.IsFull() || !c.IsDirty() {
+		t.Errorf("expected a dirty shard not full without free slots, got %d free slots, full %t, dirty %t",
+			c.FreeSlots(), c.IsFull(), c.IsDirty())
+	}
+}
@@ -0,0 +1,82 @@
+package autosync
+
+import (
+	"github.com/danielealbano/svdb/shared/collection"
+	"github.com/danielealbano/svdb/shared/support"
+	"sync"
+	"time"
+)
+
+// AutoSync periodically saves the collection to the shard path if it has been modified since the last save, the
+// collection is saved holding the read lock while the writes wait on a separate mutex, so the searches keep being
+// served while the shard is written even if a write is waiting.
+type AutoSync struct {
+	collection *shared_collection.Collection
+	path       string
+	interval   time.Duration
+	task       *shared_support.PeriodicTask
+	mutex      sync.Mutex
+	status     Status
+}
+
+// Status reports the time of the last successful sync, the number of failed syncs and the last error
+type Status struct {
+	LastSync    time.Time
+	LastFailure time.Time
+	LastError   error
+	Failures    uint64
+}
+
+func New(collection *shared_collection.Collection, path string, interval time.Duration) *AutoSync {
+	a := &AutoSync{
+		collection: collection,
+		path:       path,
+		interval:   interval,
+	}
+	a.task = shared_support.NewPeriodicTask(interval, a.check)
+
+	return a
+}
+
+func (a *AutoSync) Start() {
+	a.task.Start()
+
+	shared_support.Logger().Info().Msgf("shard auto sync enabled, saving every %s", a.interval)
+}
+
+// Stop waits for the sync in progress, if any, to complete
+func (a *AutoSync) Stop() {
+	a.task.Stop()
+}
+
+func (a *AutoSync) Status() Status {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.status
+}
+
+func (a *AutoSync) check() {
+	if a.collection.IsDirty() {
+		a.sync()
+	}
+}
+
+func (a *AutoSync) sync() {
+	start := time.Now()
+	err := a.collection.Save(a.path)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err != nil {
+		a.status.Failures++
+		a.status.LastFailure = time.Now()
+		a.status.LastError = err
+		shared_support.Logger().Error().Msgf("shard auto sync failed (%d failures): %v", a.status.Failures, err)
+		return
+	}
+
+	a.status.LastSync = time.Now()
+	shared_support.Logger().Info().Msgf("shard auto synced to %s in %s", a.path, time.Since(start))
+}
@@ -7,21 +7,33 @@ import (
 	"github.com/danielealbano/svdb/shared/collection"
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/phuslu/log"
+	"os"
 	"time"
 )
 
 type Config struct {
-	Host                       string `env:"HOST" envDefault:"0.0.0.0"`
-	Port                       int    `env:"PORT" envDefault:"3000"`
-	LogLevel                   string `env:"LOG_LEVEL" envDefault:"info"`
-	CollectionQuantization     string `env:"COLLECTION_QUANTIZATION" envDefault:"F32"`
-	CollectionMetric           string `env:"COLLECTION_METRIC" envDefault:"Cosine"`
-	CollectionVectorDimensions uint   `env:"COLLECTION_VECTOR_DIMENSIONS" envDefault:"128"`
-	ShardPath                  string `env:"SHARD_PATH"`
-	ShardWriteable             bool   `env:"SHARD_WRITEABLE" envDefault:"false"`
-	ShardMaxSize               string `env:"SHARD_MAX_SIZE" envDefault:"1GB"`
-	ShardAutoSync              bool   `env:"SHARD_AUTO_SYNC" envDefault:"false"`
-	ShardAutoSyncInterval      string `env:"SHARD_AUTO_SYNC_INTERVAL" envDefault:"1m"`
+	Host                       string  `env:"HOST" envDefault:"0.0.0.0"`
+	Port                       int     `env:"PORT" envDefault:"3000"`
+	LogLevel                   string  `env:"LOG_LEVEL" envDefault:"info"`
+	CollectionQuantization     string  `env:"COLLECTION_QUANTIZATION" envDefault:"F32"`
+	CollectionMetric           string  `env:"COLLECTION_METRIC" envDefault:"Cosine"`
+	CollectionVectorDimensions uint    `env:"COLLECTION_VECTOR_DIMENSIONS" envDefault:"128"`
+	CollectionMulti            bool    `env:"COLLECTION_MULTI" envDefault:"false"`
+	CollectionRescore          bool    `env:"COLLECTION_RESCORE" envDefault:"false"`
+	CollectionNormalization    string  `env:"COLLECTION_NORMALIZATION" envDefault:"none"`
+	CollectionVectorValidation string  `env:"COLLECTION_VECTOR_VALIDATION"`
+	ShardPath                  string  `env:"SHARD_PATH"`
+	ShardWriteable             bool    `env:"SHARD_WRITEABLE" envDefault:"false"`
+	ShardMemoryMapped          bool    `env:"SHARD_MEMORY_MAPPED" envDefault:"false"`
+	ShardMaxSize               string  `env:"SHARD_MAX_SIZE" envDefault:"1GB"`
+	ShardWAL                   bool    `env:"SHARD_WAL" envDefault:"false"`
+	ShardWALSync               string  `env:"SHARD_WAL_SYNC" envDefault:"always"`
+	ShardWALSyncInterval       string  `env:"SHARD_WAL_SYNC_INTERVAL" envDefault:"1s"`
+	ShardAutoSync              bool    `env:"SHARD_AUTO_SYNC" envDefault:"false"`
+	ShardAutoSyncInterval      string  `env:"SHARD_AUTO_SYNC_INTERVAL" envDefault:"1m"`
+	ShardAutoCompact           bool    `env:"SHARD_AUTO_COMPACT" envDefault:"true"`
+	ShardAutoCompactInterval   string  `env:"SHARD_AUTO_COMPACT_INTERVAL" envDefault:"10m"`
+	ShardAutoCompactFreeRatio  float64 `env:"SHARD_AUTO_COMPACT_FREE_RATIO" envDefault:"0.2"`
 }
 
 func ParseShardMaxSize(size string) (uint, error) {
@@ -34,6 +46,12 @@ func ParseShardMaxSize(size string) (uint, error) {
 	return uint(v), nil
 }
 
+// IsSetInEnv returns true if the environment variable is set, including the ones loaded from the .env file
+func IsSetInEnv(name string) bool {
+	_, found := os.LookupEnv(name)
+	return found
+}
+
 func FromEnv() (*Config, error) {
 	config := Config{}
 	if err := env.Parse(&config); err != nil {
@@ -51,6 +69,7 @@ func ValidateConfig(config *Config) error {
 	var err error
 	var maxSize uint
 	var interval time.Duration
+	var walSync shared_collection.WALSyncPolicy
 
 	if config.Host == "" {
 		return fmt.Errorf("host is required")
@@ -74,10 +93,31 @@ func ValidateConfig(config *Config) error {
 		return fmt.Errorf("invalid collection quantization: %s", config.CollectionQuantization)
 	}
 
-	if _, err = shared_collection.ParseMetric(config.CollectionMetric); err != nil {
+	metric, err := shared_collection.ParseMetric(config.CollectionMetric)
+	if err != nil {
 		return fmt.Errorf("invalid collection metric: %s", config.CollectionMetric)
 	}
 
+	normalization, err := shared_collection.ParseNormalizationPolicy(config.CollectionNormalization)
+	if err != nil {
+		return fmt.Errorf("invalid collection normalization: %s", config.CollectionNormalization)
+	}
+
+	if normalization != shared_collection.NormalizationNone && !metric.SupportsNormalization() {
+		return fmt.Errorf("collection normalization requires the cosine or innerproduct metric")
+	}
+
+	// If not set the validation depends on the metric of the collection
+	if config.CollectionVectorValidation != "" {
+		if _, err = shared_collection.ParseVectorValidation(config.CollectionVectorValidation); err != nil {
+			return fmt.Errorf("invalid collection vector validation: %s", config.CollectionVectorValidation)
+		}
+	}
+
+	if config.ShardWriteable && config.ShardMemoryMapped {
+		return fmt.Errorf("a memory mapped shard can't be writeable")
+	}
+
 	if config.ShardWriteable {
 		maxSize, err = ParseShardMaxSize(config.ShardMaxSize)
 		if err != nil {
@@ -88,6 +128,24 @@ func ValidateConfig(config *Config) error {
 			return fmt.Errorf("shard max size must be greater than 0")
 		}
 
+		if config.ShardWAL {
+			walSync, err = shared_collection.ParseWALSyncPolicy(config.ShardWALSync)
+			if err != nil {
+				return fmt.Errorf("invalid shard wal sync policy: %s", config.ShardWALSync)
+			}
+
+			if walSync == shared_collection.WALSyncInterval {
+				interval, err = time.ParseDuration(config.ShardWALSyncInterval)
+				if err != nil {
+					return fmt.Errorf("failed to parse the shard wal sync interval: %w", err)
+				}
+
+				if interval <= 0 {
+					return fmt.Errorf("shard wal sync interval must be greater than 0")
+				}
+			}
+		}
+
 		if config.ShardAutoSync {
 			interval, err = time.ParseDuration(config.ShardAutoSyncInterval)
 			if err != nil {
@@ -98,6 +156,21 @@ func ValidateConfig(config *Config) error {
 				return fmt.Errorf("shard auto sync interval must be greater than 0")
 			}
 		}
+
+		if config.ShardAutoCompact {
+			interval, err = time.ParseDuration(config.ShardAutoCompactInterval)
+			if err != nil {
+				return fmt.Errorf("failed to parse the shard auto compact interval: %w", err)
+			}
+
+			if interval <= 0 {
+				return fmt.Errorf("shard auto compact interval must be greater than 0")
+			}
+
+			if config.ShardAutoCompactFreeRatio <= 0 || config.ShardAutoCompactFreeRatio > 1 {
+				return fmt.Errorf("shard auto compact free ratio must be greater than 0 and at most 1")
+			}
+		}
 	}
 
 	return nil
@@ -1,7 +1,10 @@
 package program
 
 import (
+	"errors"
 	"fmt"
+	"github.com/danielealbano/svdb/engine-worker/autocompact"
+	"github.com/danielealbano/svdb/engine-worker/autosync"
 	"github.com/danielealbano/svdb/engine-worker/config"
 	"github.com/danielealbano/svdb/engine-worker/server"
 	"github.com/danielealbano/svdb/shared/collection"
@@ -10,14 +13,17 @@ import (
 	"github.com/phuslu/log"
 	"net"
 	"os"
+	"path/filepath"
 	"time"
 )
 
 type Program struct {
-	config     *config.Config
-	collection *shared_collection.Collection
-	server     *shared_grpc_server.GrpcServer
-	running    bool
+	config      *config.Config
+	collection  *shared_collection.Collection
+	server      *shared_grpc_server.GrpcServer
+	autoSync    *autosync.AutoSync
+	autoCompact *autocompact.AutoCompact
+	running     bool
 }
 
 func NewProgram(config *config.Config) *Program {
@@ -41,6 +47,34 @@ func (p *Program) initializeCollection(shardExists bool) (*shared_collection.Col
 	collectionConfig.Dimensions = p.config.CollectionVectorDimensions
 	collectionConfig.Quantization, _ = shared_collection.ParseQuantization(p.config.CollectionQuantization)
 	collectionConfig.Metric, _ = shared_collection.ParseMetric(p.config.CollectionMetric)
+	collectionConfig.Multi = p.config.CollectionMulti
+	collectionConfig.Rescore = p.config.CollectionRescore
+	collectionConfig.TempDir = filepath.Dir(p.config.ShardPath)
+	collectionConfig.Normalization, _ = shared_collection.ParseNormalizationPolicy(p.config.CollectionNormalization)
+
+	// Adopt the settings stored in the manifest of the shard that are not set in the environment, the collection
+	// refuses to load the shard if the others don't match
+	if shardExists {
+		err := p.adoptShardManifest(collectionConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// If not set the validation depends on the metric, known only once the manifest has been read
+	collectionConfig.VectorValidation = shared_collection.DefaultVectorValidation(collectionConfig.Metric)
+	if p.config.CollectionVectorValidation != "" {
+		collectionConfig.VectorValidation, _ = shared_collection.ParseVectorValidation(
+			p.config.CollectionVectorValidation)
+	}
+
+	// The metric may have been adopted from the manifest after the configuration has been validated
+	if collectionConfig.Normalization != shared_collection.NormalizationNone &&
+		!collectionConfig.Metric.SupportsNormalization() {
+		return nil, fmt.Errorf(
+			"collection normalization requires the cosine or innerproduct metric, the shard uses %s",
+			collectionConfig.Metric)
+	}
 
 	// Initialize the collection
 	coll, err := shared_collection.NewCollection(collectionConfig)
@@ -48,17 +82,102 @@ func (p *Program) initializeCollection(shardExists bool) (*shared_collection.Col
 		return nil, err
 	}
 
-	// Load the shard if it exists
-	if shardExists {
+	// Load the shard if it exists, read-only shards can be memory mapped to avoid copying them in memory
+	if shardExists && p.config.ShardMemoryMapped {
+		err = coll.View(p.config.ShardPath)
+		if err != nil {
+			return nil, err
+		}
+	} else if shardExists {
 		err = coll.Load(p.config.ShardPath)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	// Replay the writes done since the shard was last saved
+	if p.config.ShardWriteable && p.config.ShardWAL {
+		err = p.openWAL(coll)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return coll, nil
 }
 
+func (p *Program) adoptShardManifest(collectionConfig *shared_collection.CollectionConfig) error {
+	manifest, err := shared_collection.LoadManifest(p.config.ShardPath)
+	if errors.Is(err, os.ErrNotExist) {
+		shared_support.Logger().Warn().Msgf("shard %s has no manifest, using the configured settings", p.config.ShardPath)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !config.IsSetInEnv("COLLECTION_VECTOR_DIMENSIONS") {
+		collectionConfig.Dimensions = manifest.Dimensions
+	}
+
+	if !config.IsSetInEnv("COLLECTION_METRIC") {
+		collectionConfig.Metric, err = shared_collection.ParseMetric(manifest.Metric)
+		if err != nil {
+			return fmt.Errorf("invalid shard manifest: %w", err)
+		}
+	}
+
+	if !config.IsSetInEnv("COLLECTION_QUANTIZATION") {
+		collectionConfig.Quantization, err = shared_collection.ParseQuantization(manifest.Quantization)
+		if err != nil {
+			return fmt.Errorf("invalid shard manifest: %w", err)
+		}
+	}
+
+	if !config.IsSetInEnv("COLLECTION_MULTI") {
+		collectionConfig.Multi = manifest.Multi
+	}
+
+	if !config.IsSetInEnv("COLLECTION_RESCORE") {
+		collectionConfig.Rescore = manifest.Rescore
+	}
+
+	if !config.IsSetInEnv("SHARD_MAX_SIZE") {
+		collectionConfig.MaxSize = manifest.MaxSize
+	}
+
+	collectionConfig.Connectivity = manifest.Connectivity
+
+	return nil
+}
+
+func (p *Program) openWAL(coll *shared_collection.Collection) error {
+	var interval time.Duration
+
+	policy, _ := shared_collection.ParseWALSyncPolicy(p.config.ShardWALSync)
+	if policy == shared_collection.WALSyncInterval {
+		interval, _ = time.ParseDuration(p.config.ShardWALSyncInterval)
+	}
+
+	result, err := coll.OpenWAL(p.config.ShardPath, policy, interval)
+	if err != nil {
+		return err
+	}
+
+	if result.Stale {
+		shared_support.Logger().Warn().Msg("write-ahead log discarded as it doesn't match the shard, it was already saved")
+	}
+
+	if result.DiscardedBytes > 0 {
+		shared_support.Logger().Warn().Msgf(
+			"discarded %d bytes of incomplete records at the end of the write-ahead log",
+			result.DiscardedBytes)
+	}
+
+	shared_support.Logger().Info().Msgf("replayed %d records from the write-ahead log", result.Records)
+
+	return nil
+}
+
 func (p *Program) setupGrpcServer() (*shared_grpc_server.GrpcServer, error) {
 	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", p.config.Host, p.config.Port))
 	if err != nil {
@@ -66,7 +185,7 @@ func (p *Program) setupGrpcServer() (*shared_grpc_server.GrpcServer, error) {
 	}
 
 	grpcServer := shared_grpc_server.NewGrpcServer(&listener)
-	server.RegisterCollectionGrpcServerImplementation(grpcServer, p.collection, p.config.ShardPath)
+	server.RegisterCollectionGrpcServerImplementation(grpcServer, p.collection, p.config.ShardPath, p.autoSync)
 
 	return grpcServer, nil
 }
@@ -90,6 +209,17 @@ func (p *Program) Shutdown() {
 		shared_support.Logger().Info().Msg("gRPC server stopped")
 	}
 
+	// Stop the auto compaction and the auto sync before the last save
+	if p.autoCompact != nil {
+		p.autoCompact.Stop()
+		shared_support.Logger().Info().Msg("shard auto compaction stopped")
+	}
+
+	if p.autoSync != nil {
+		p.autoSync.Stop()
+		shared_support.Logger().Info().Msg("shard auto sync stopped")
+	}
+
 	// Save the shard if it is writeable
 	if p.collection != nil && p.config.ShardWriteable {
 		shared_support.Logger().Info().Msgf("saving shard to %s", p.config.ShardPath)
@@ -109,6 +239,13 @@ func (p *Program) Start() {
 	// Update the logger level
 	p.updateLoggerLevel()
 
+	// Complete the save interrupted by a crash, if any, before looking at the files of the shard
+	err = shared_collection.RecoverShard(p.config.ShardPath)
+	if err != nil {
+		shared_support.Logger().Error().Msg(err.Error())
+		return
+	}
+
 	// Check if the path exists
 	shardExists = true
 	if _, err = os.Stat(p.config.ShardPath); os.IsNotExist(err) {
@@ -123,11 +260,28 @@ func (p *Program) Start() {
 
 	// Initialize the collection
 	p.collection, err = p.initializeCollection(shardExists)
-	if err != nil {
+	if errors.Is(err, shared_collection.ErrShardCorrupted) {
+		shared_support.Logger().Error().Msgf("shard %s is corrupted and must be restored: %v", p.config.ShardPath, err)
+		return
+	} else if err != nil {
 		shared_support.Logger().Error().Msg(err.Error())
 		return
 	}
 
+	// Start saving the shard periodically if it is modified
+	if p.config.ShardWriteable && p.config.ShardAutoSync {
+		interval, _ := time.ParseDuration(p.config.ShardAutoSyncInterval)
+		p.autoSync = autosync.New(p.collection, p.config.ShardPath, interval)
+		p.autoSync.Start()
+	}
+
+	// Start compacting the shard periodically to release the space of the removed vectors
+	if p.config.ShardWriteable && p.config.ShardAutoCompact {
+		interval, _ := time.ParseDuration(p.config.ShardAutoCompactInterval)
+		p.autoCompact = autocompact.New(p.collection, interval, p.config.ShardAutoCompactFreeRatio)
+		p.autoCompact.Start()
+	}
+
 	// Start the gRPC server
 	p.server, err = p.setupGrpcServer()
 	if err != nil {
@@ -138,6 +292,11 @@ func (p *Program) Start() {
 }
 
 func (p *Program) Wait() {
+	// The server is not running if the program failed to start
+	if p.server == nil {
+		return
+	}
+
 	shared_support.WaitMultipleChannels(
 		500*time.Millisecond,
 		shared_support.StopSignal.Context.Done(),
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"errors"
+	"github.com/danielealbano/svdb/shared/collection"
+	shared_proto_build_collection "github.com/danielealbano/svdb/shared/proto/build/collection"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math"
+	"unsafe"
+)
+
+// searchOptionsRequest is implemented by both SearchRequest and SearchMultiRequest
+type searchOptionsRequest interface {
+	GetKeysFilter() *shared_proto_build_collection.KeysFilter
+	GetIncludeMetadata() bool
+	GetFilter() string
+	GetExact() bool
+	GetRescoreFactor() uint32
+}
+
+func addOutcomesToPB(outcomes []shared_collection.AddOutcome) []shared_proto_build_collection.AddOutcome {
+	return *(*[]shared_proto_build_collection.AddOutcome)(unsafe.Pointer(&outcomes))
+}
+
+func deleteOutcomesToPB(outcomes []shared_collection.DeleteOutcome) []shared_proto_build_collection.DeleteOutcome {
+	return *(*[]shared_proto_build_collection.DeleteOutcome)(unsafe.Pointer(&outcomes))
+}
+
+func searchOptionsFromPB(req searchOptionsRequest) (*shared_collection.SearchOptions, error) {
+	options := &shared_collection.SearchOptions{
+		IncludeMetadata: req.GetIncludeMetadata(),
+		Exact:           req.GetExact(),
+		RescoreFactor:   req.GetRescoreFactor(),
+	}
+
+	if options.RescoreFactor > shared_collection.RescoreMaxFactor {
+		return nil, status.Errorf(
+			codes.InvalidArgument,
+			"rescore factor must be less than or equal to %d",
+			shared_collection.RescoreMaxFactor)
+	}
+
+	if keysFilter := req.GetKeysFilter(); keysFilter != nil {
+		switch keysFilter.Mode {
+		case shared_proto_build_collection.KeysFilterMode_KEYS_FILTER_MODE_ALLOW,
+			shared_proto_build_collection.KeysFilterMode_KEYS_FILTER_MODE_DENY:
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "invalid keys filter mode: %d", keysFilter.Mode)
+		}
+
+		options.KeysFilter = shared_collection.NewKeysFilter(
+			*(*[]shared_collection.Key)(unsafe.Pointer(&keysFilter.Keys)),
+			keysFilter.Mode == shared_proto_build_collection.KeysFilterMode_KEYS_FILTER_MODE_DENY)
+	}
+
+	if req.GetFilter() != "" {
+		filter, err := shared_collection.ParseFilter(req.GetFilter())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+
+		options.Filter = filter
+	}
+
+	return options, nil
+}
+
+// radiusSearchFromPB turns the search into a radius search and returns the max number of keys to return, a maxResults
+// of 0 means the max allowed
+func radiusSearchFromPB(options *shared_collection.SearchOptions, maxDistance float32, maxResults uint32) (uint32, error) {
+	if math.IsNaN(float64(maxDistance)) {
+		return 0, status.Errorf(codes.InvalidArgument, "max distance must be a number")
+	}
+
+	options.MaxDistance = &maxDistance
+
+	if maxResults == 0 {
+		return shared_collection.RadiusSearchMaxResults, nil
+	}
+
+	if maxResults > shared_collection.RadiusSearchMaxResults {
+		return 0, status.Errorf(
+			codes.InvalidArgument,
+			"max results must be less than or equal to %d",
+			shared_collection.RadiusSearchMaxResults)
+	}
+
+	return maxResults, nil
+}
+
+// hybridSearchFromPB turns the search into a hybrid search blending the text query, a nil textWeight means the default
+// weight
+func hybridSearchFromPB(
+	options *shared_collection.SearchOptions,
+	textQuery string,
+	fusion shared_proto_build_collection.FusionStrategy,
+	textWeight *float32) error {
+	strategy := shared_collection.FusionStrategy(fusion)
+	if !strategy.IsValid() {
+		return status.Errorf(codes.InvalidArgument, "invalid fusion strategy: %d", fusion)
+	}
+
+	weight := float32(shared_collection.HybridDefaultTextWeight)
+	if textWeight != nil {
+		weight = *textWeight
+	}
+
+	if !(weight >= 0 && weight <= 1) {
+		return status.Errorf(codes.InvalidArgument, "text weight must be between 0 and 1")
+	}
+
+	options.TextQuery = textQuery
+	options.Fusion = strategy
+	options.TextWeight = weight
+
+	return nil
+}
+
+// errorToStatus converts the errors caused by the request, or by the state of the shard, to the matching codes
+func errorToStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, shared_collection.ErrInvalidFilter) ||
+		errors.Is(err, shared_collection.ErrInvalidSnapshotLabel) ||
+		errors.Is(err, shared_collection.ErrNotNormalized) ||
+		errors.Is(err, shared_collection.ErrInvalidVector) {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	if errors.Is(err, shared_collection.ErrSnapshotNotFound) {
+		return status.Errorf(codes.NotFound, "%v", err)
+	}
+
+	if errors.Is(err, shared_collection.ErrSnapshotExists) {
+		return status.Errorf(codes.AlreadyExists, "%v", err)
+	}
+
+	if errors.Is(err, shared_collection.ErrKeysNotTracked) ||
+		errors.Is(err, shared_collection.ErrNoQueries) ||
+		errors.Is(err, shared_collection.ErrReadOnly) ||
+		errors.Is(err, shared_collection.ErrRescoreDisabled) {
+		return status.Errorf(codes.FailedPrecondition, "%v", err)
+	}
+
+	return err
+}
+
+func snapshotInfoToPB(info shared_collection.SnapshotInfo) *shared_proto_build_collection.SnapshotInfo {
+	return &shared_proto_build_collection.SnapshotInfo{
+		Label:             info.Label,
+		SavedAtUnixMillis: info.SavedAt.UnixMilli(),
+		Size:              info.Size,
+	}
+}
+
+func exportPageToPB(page shared_collection.ExportPage) *shared_proto_build_collection.ExportPage {
+	response := &shared_proto_build_collection.ExportPage{
+		Entries:    make([]*shared_proto_build_collection.ExportEntry, len(page.Entries)),
+		NextCursor: uint64(page.NextCursor),
+		Done:       page.Done,
+	}
+
+	for i, entry := range page.Entries {
+		response.Entries[i] = &shared_proto_build_collection.ExportEntry{
+			Key:      uint64(entry.Key),
+			Vectors:  vectorsToPB(entry.Vectors),
+			Metadata: metadataToPB(entry.Metadata),
+			Text:     entry.Text,
+		}
+	}
+
+	return response
+}
+
+func searchResultToPB(result shared_collection.SearchResult) *shared_proto_build_collection.SearchResponse {
+	response := &shared_proto_build_collection.SearchResponse{
+		Keys:      *(*[]uint64)(unsafe.Pointer(&result.Keys)),
+		Distances: result.Distances,
+		Scores:    result.Scores,
+	}
+
+	if result.Metadata != nil {
+		response.Metadata = make([]*shared_proto_build_collection.Metadata, len(result.Metadata))
+		for i, metadata := range result.Metadata {
+			response.Metadata[i] = metadataToPB(metadata)
+		}
+	}
+
+	return response
+}
+
+func metadataFromPB(metadata *shared_proto_build_collection.Metadata) (shared_collection.Metadata, error) {
+	if metadata == nil || len(metadata.Fields) == 0 {
+		return nil, nil
+	}
+
+	result := make(shared_collection.Metadata, len(metadata.Fields))
+	for field, value := range metadata.Fields {
+		switch v := value.GetValue().(type) {
+		case *shared_proto_build_collection.MetadataValue_StringValue:
+			result[field] = v.StringValue
+		case *shared_proto_build_collection.MetadataValue_NumberValue:
+			if math.IsNaN(v.NumberValue) || math.IsInf(v.NumberValue, 0) {
+				return nil, status.Errorf(
+					codes.InvalidArgument,
+					"metadata field %s has non-finite value %g",
+					field,
+					v.NumberValue)
+			}
+			result[field] = v.NumberValue
+		case *shared_proto_build_collection.MetadataValue_BoolValue:
+			result[field] = v.BoolValue
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "metadata field %s has no value", field)
+		}
+	}
+
+	return result, nil
+}
+
+func metadataToPB(metadata shared_collection.Metadata) *shared_proto_build_collection.Metadata {
+	if metadata == nil {
+		return nil
+	}
+
+	result := &shared_proto_build_collection.Metadata{
+		Fields: make(map[string]*shared_proto_build_collection.MetadataValue, len(metadata)),
+	}
+	for field, value := range metadata {
+		switch v := value.(type) {
+		case string:
+			result.Fields[field] = &shared_proto_build_collection.MetadataValue{
+				Value: &shared_proto_build_collection.MetadataValue_StringValue{StringValue: v},
+			}
+		case float64:
+			result.Fields[field] = &shared_proto_build_collection.MetadataValue{
+				Value: &shared_proto_build_collection.MetadataValue_NumberValue{NumberValue: v},
+			}
+		case bool:
+			result.Fields[field] = &shared_proto_build_collection.MetadataValue{
+				Value: &shared_proto_build_collection.MetadataValue_BoolValue{BoolValue: v},
+			}
+		}
+	}
+
+	return result
+}
+
+func latencyPercentilesToPB(latency shared_collection.LatencyPercentiles) *shared_proto_build_collection.LatencyPercentiles {
+	return &shared_proto_build_collection.LatencyPercentiles{
+		P50Micros: uint64(latency.P50.Microseconds()),
+		P90Micros: uint64(latency.P90.Microseconds()),
+		P99Micros: uint64(latency.P99.Microseconds()),
+		MaxMicros: uint64(latency.Max.Microseconds()),
+	}
+}
+
+func evaluationResultToPB(result shared_collection.EvaluationResult) *shared_proto_build_collection.EvaluateResponse {
+	return &shared_proto_build_collection.EvaluateResponse{
+		Queries:            result.Queries,
+		K:                  result.K,
+		Recall:             result.Recall,
+		ApproximateLatency: latencyPercentilesToPB(result.ApproximateLatency),
+		ExactLatency:       latencyPercentilesToPB(result.ExactLatency),
+		Settings: &shared_proto_build_collection.EvaluationSettings{
+			Dimensions:      uint64(result.Settings.Dimensions),
+			Metric:          result.Settings.Metric,
+			Quantization:    result.Settings.Quantization,
+			Connectivity:    uint64(result.Settings.Connectivity),
+			ExpansionAdd:    uint64(result.Settings.ExpansionAdd),
+			ExpansionSearch: uint64(result.Settings.ExpansionSearch),
+			Multi:           result.Settings.Multi,
+			Length:          uint64(result.Settings.Length),
+		},
+	}
+}
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"errors"
+	"github.com/danielealbano/svdb/engine-worker/autosync"
 	"github.com/danielealbano/svdb/shared/collection"
 	shared_grpc_server "github.com/danielealbano/svdb/shared/grpc_server"
 	shared_proto_build_collection "github.com/danielealbano/svdb/shared/proto/build/collection"
@@ -10,23 +12,43 @@ import (
 	"unsafe"
 )
 
+// evaluateDefaultSample is the number of vectors sampled from the shard when an evaluation has no queries
+const evaluateDefaultSample = 100
+
+// exportDefaultPageSize is the number of keys per page when an export has no page size
+const exportDefaultPageSize = 1000
+
 type collectionGrpcServerImplementation struct {
 	shared_proto_build_collection.UnimplementedCollectionServer
 	collection     *shared_collection.Collection
 	collectionPath string
+	autoSync       *autosync.AutoSync
+	normalizer     *shared_collection.Normalizer
 }
 
 func vectorToPB(v []float32) *shared_proto_build_collection.Vector {
 	return &shared_proto_build_collection.Vector{Values: v}
 }
 
+func vectorsToPB(vectors []shared_collection.Vector) []*shared_proto_build_collection.Vector {
+	pbVectors := make([]*shared_proto_build_collection.Vector, len(vectors))
+	for i, v := range vectors {
+		pbVectors[i] = vectorToPB(v)
+	}
+
+	return pbVectors
+}
+
 func RegisterCollectionGrpcServerImplementation(
 	server *shared_grpc_server.GrpcServer,
 	coll *shared_collection.Collection,
-	path string) {
+	path string,
+	autoSync *autosync.AutoSync) {
 	shared_proto_build_collection.RegisterCollectionServer(server.GrpcServer, &collectionGrpcServerImplementation{
 		collection:     coll,
 		collectionPath: path,
+		autoSync:       autoSync,
+		normalizer:     shared_collection.NewNormalizer(coll.Config.Normalization),
 	})
 }
 
@@ -43,19 +65,131 @@ func (s *collectionGrpcServerImplementation) Search(
 			status.Errorf(
 				codes.InvalidArgument,
 				"expected %d dimensions, got %d",
-				len(req.Query.Values),
-				s.collection.Config.Dimensions)
+				s.collection.Config.Dimensions,
+				len(req.Query.Values))
 	}
 
-	keys, distances, err := s.collection.Search(req.Query.Values, req.Limit)
+	err := s.collection.Config.VectorValidation.Validate("query", []shared_collection.Vector{req.Query.Values})
 	if err != nil {
-		return nil, err
+		return &shared_proto_build_collection.SearchResponse{}, errorToStatus(err)
 	}
 
-	return &shared_proto_build_collection.SearchResponse{
-		Keys:      *(*[]uint64)(unsafe.Pointer(&keys)),
-		Distances: distances,
-	}, nil
+	err = s.normalizer.Apply("query", []shared_collection.Vector{req.Query.Values})
+	if err != nil {
+		return &shared_proto_build_collection.SearchResponse{}, errorToStatus(err)
+	}
+
+	options, err := searchOptionsFromPB(req)
+	if err != nil {
+		return &shared_proto_build_collection.SearchResponse{}, err
+	}
+
+	limit := req.Limit
+	if req.MaxDistance != nil {
+		limit, err = radiusSearchFromPB(options, *req.MaxDistance, req.MaxResults)
+		if err != nil {
+			return &shared_proto_build_collection.SearchResponse{}, err
+		}
+	} else if limit <= 0 {
+		return &shared_proto_build_collection.SearchResponse{},
+			status.Errorf(codes.InvalidArgument, "limit must be greater than 0")
+	}
+
+	if req.TextQuery != "" {
+		err = hybridSearchFromPB(options, req.TextQuery, req.Fusion, req.TextWeight)
+		if err != nil {
+			return &shared_proto_build_collection.SearchResponse{}, err
+		}
+	}
+
+	result, err := s.collection.Search(req.Query.Values, limit, options)
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+
+	return searchResultToPB(result), nil
+}
+
+func (s *collectionGrpcServerImplementation) SearchMulti(
+	_ context.Context,
+	req *shared_proto_build_collection.SearchMultiRequest) (*shared_proto_build_collection.SearchMultiResponse, error) {
+	if req == nil || len(req.Queries) == 0 {
+		return &shared_proto_build_collection.SearchMultiResponse{},
+			status.Errorf(codes.InvalidArgument, "request empty or missing arguments")
+	}
+
+	queries := make([]shared_collection.Vector, len(req.Queries))
+	for i, q := range req.Queries {
+		if q == nil || len(q.Values) != int(s.collection.Config.Dimensions) {
+			return &shared_proto_build_collection.SearchMultiResponse{},
+				status.Errorf(
+					codes.InvalidArgument,
+					"query %d, expected %d dimensions, got %d",
+					i,
+					s.collection.Config.Dimensions,
+					len(q.GetValues()))
+		}
+
+		queries[i] = q.Values
+	}
+
+	err := s.collection.Config.VectorValidation.Validate("query", queries)
+	if err != nil {
+		return &shared_proto_build_collection.SearchMultiResponse{}, errorToStatus(err)
+	}
+
+	err = s.normalizer.Apply("query", queries)
+	if err != nil {
+		return &shared_proto_build_collection.SearchMultiResponse{}, errorToStatus(err)
+	}
+
+	options, err := searchOptionsFromPB(req)
+	if err != nil {
+		return &shared_proto_build_collection.SearchMultiResponse{}, err
+	}
+
+	limits := req.Limits
+	if req.MaxDistance != nil {
+		limit, err := radiusSearchFromPB(options, *req.MaxDistance, req.MaxResults)
+		if err != nil {
+			return &shared_proto_build_collection.SearchMultiResponse{}, err
+		}
+
+		limits = []uint32{limit}
+	} else if len(limits) == 0 {
+		limits = []uint32{req.Limit}
+	} else if len(limits) != len(req.Queries) {
+		return &shared_proto_build_collection.SearchMultiResponse{},
+			status.Errorf(codes.InvalidArgument, "queries and limits must have the same length")
+	}
+
+	for i, limit := range limits {
+		if limit <= 0 {
+			return &shared_proto_build_collection.SearchMultiResponse{},
+				status.Errorf(codes.InvalidArgument, "limit %d must be greater than 0", i)
+		}
+	}
+
+	if req.TextQuery != "" {
+		err = hybridSearchFromPB(options, req.TextQuery, req.Fusion, req.TextWeight)
+		if err != nil {
+			return &shared_proto_build_collection.SearchMultiResponse{}, err
+		}
+	}
+
+	results, err := s.collection.SearchMulti(queries, limits, options)
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+
+	response := &shared_proto_build_collection.SearchMultiResponse{
+		Results: make([]*shared_proto_build_collection.SearchResponse, len(results)),
+	}
+	for i, result := range results {
+		response.Results[i] = searchResultToPB(result)
+	}
+
+	return response, nil
 }
 
 func (s *collectionGrpcServerImplementation) Add(
@@ -71,14 +205,38 @@ func (s *collectionGrpcServerImplementation) Add(
 			status.Errorf(
 				codes.InvalidArgument,
 				"expected %d dimensions, got %d",
-				len(req.Vector.Values),
-				s.collection.Config.Dimensions)
+				s.collection.Config.Dimensions,
+				len(req.Vector.Values))
+	}
+
+	mode := shared_collection.WriteMode(req.Mode)
+	if !mode.IsValid() {
+		return &shared_proto_build_collection.AddResponse{},
+			status.Errorf(codes.InvalidArgument, "invalid write mode: %d", req.Mode)
+	}
+
+	// The metadata is checked before the vector is normalized, a request refused doesn't count in the stats
+	metadata, err := metadataFromPB(req.Metadata)
+	if err != nil {
+		return &shared_proto_build_collection.AddResponse{}, err
 	}
 
-	_, isFull, err := s.collection.Add(shared_collection.Key(req.Key), req.Vector.Values)
+	err = s.collection.Config.VectorValidation.Validate("vector", []shared_collection.Vector{req.Vector.Values})
+	if err != nil {
+		return &shared_proto_build_collection.AddResponse{}, errorToStatus(err)
+	}
+
+	err = s.normalizer.Apply("vector", []shared_collection.Vector{req.Vector.Values})
+	if err != nil {
+		return &shared_proto_build_collection.AddResponse{}, errorToStatus(err)
+	}
+
+	result, err := s.collection.Add(shared_collection.Key(req.Key), req.Vector.Values, metadata, req.Text, mode)
 	return &shared_proto_build_collection.AddResponse{
-		ShardFull: isFull,
-	}, err
+		ShardFull: result.IsFull,
+		Headroom:  uint64(result.Headroom),
+		Outcome:   shared_proto_build_collection.AddOutcome(result.Outcomes[0]),
+	}, errorToStatus(err)
 }
 
 func (s *collectionGrpcServerImplementation) AddMulti(
@@ -99,31 +257,85 @@ func (s *collectionGrpcServerImplementation) AddMulti(
 			status.Errorf(codes.InvalidArgument, "no data provided")
 	}
 
+	mode := shared_collection.WriteMode(req.Mode)
+	if !mode.IsValid() {
+		return &shared_proto_build_collection.AddMultiResponse{},
+			status.Errorf(codes.InvalidArgument, "invalid write mode: %d", req.Mode)
+	}
+
+	var err error
+	var metadata []shared_collection.Metadata
+	if len(req.Metadata) > 0 {
+		if len(req.Metadata) != len(req.Keys) {
+			return &shared_proto_build_collection.AddMultiResponse{},
+				status.Errorf(codes.InvalidArgument, "keys and metadata must have the same length")
+		}
+
+		metadata = make([]shared_collection.Metadata, len(req.Metadata))
+		for i, m := range req.Metadata {
+			metadata[i], err = metadataFromPB(m)
+			if err != nil {
+				return &shared_proto_build_collection.AddMultiResponse{}, err
+			}
+		}
+	}
+
+	var texts []string
+	if len(req.Texts) > 0 {
+		if len(req.Texts) != len(req.Keys) {
+			return &shared_proto_build_collection.AddMultiResponse{},
+				status.Errorf(codes.InvalidArgument, "keys and texts must have the same length")
+		}
+
+		texts = req.Texts
+	}
+
 	vectors := make([][]float32, len(req.Vectors))
 	for i, v := range req.Vectors {
-		if len(req.Vectors[0].Values) != int(s.collection.Config.Dimensions) {
+		if v == nil || len(v.Values) != int(s.collection.Config.Dimensions) {
 			return &shared_proto_build_collection.AddMultiResponse{},
 				status.Errorf(
 					codes.InvalidArgument,
 					"vector %d, expected %d dimensions, got %d",
 					i,
-					len(req.Vectors[0].Values),
-					s.collection.Config.Dimensions)
+					s.collection.Config.Dimensions,
+					len(v.GetValues()))
 		}
 
 		vectors[i] = v.Values
 	}
 
-	inserted, isFull, err := s.collection.AddMulti(
-		*(*[]shared_collection.Key)(unsafe.Pointer(&req.Keys)),
+	err = s.collection.Config.VectorValidation.Validate(
+		"vector",
 		*(*[]shared_collection.Vector)(unsafe.Pointer(&vectors)))
+	if err != nil {
+		return &shared_proto_build_collection.AddMultiResponse{}, errorToStatus(err)
+	}
+
+	err = s.normalizer.Apply("vector", *(*[]shared_collection.Vector)(unsafe.Pointer(&vectors)))
+	if err != nil {
+		return &shared_proto_build_collection.AddMultiResponse{}, errorToStatus(err)
+	}
+
+	result, err := s.collection.AddMulti(
+		*(*[]shared_collection.Key)(unsafe.Pointer(&req.Keys)),
+		*(*[]shared_collection.Vector)(unsafe.Pointer(&vectors)),
+		metadata,
+		texts,
+		mode)
+
+	if errors.Is(err, shared_collection.ErrReadOnly) {
+		return &shared_proto_build_collection.AddMultiResponse{}, errorToStatus(err)
+	}
 
 	if err != nil {
 		var err2 error
 		serr := status.Newf(codes.Internal, "failed to add vectors: %v", err)
 		serr, err2 = serr.WithDetails(&shared_proto_build_collection.AddMultiResponse{
-			Inserted:  inserted,
-			ShardFull: isFull,
+			Inserted:  result.Inserted,
+			ShardFull: result.IsFull,
+			Headroom:  uint64(result.Headroom),
+			Outcomes:  addOutcomesToPB(result.Outcomes),
 		})
 		if err2 != nil {
 			return &shared_proto_build_collection.AddMultiResponse{},
@@ -136,8 +348,10 @@ func (s *collectionGrpcServerImplementation) AddMulti(
 	}
 
 	return &shared_proto_build_collection.AddMultiResponse{
-		Inserted:  inserted,
-		ShardFull: isFull,
+		Inserted:  result.Inserted,
+		ShardFull: result.IsFull,
+		Headroom:  uint64(result.Headroom),
+		Outcomes:  addOutcomesToPB(result.Outcomes),
 	}, err
 }
 
@@ -149,17 +363,15 @@ func (s *collectionGrpcServerImplementation) Get(
 			status.Errorf(codes.InvalidArgument, "request empty or missing arguments")
 	}
 
-	if req.Count <= 0 {
-		return &shared_proto_build_collection.GetResponse{},
-			status.Errorf(codes.InvalidArgument, "count must be greater than 0")
-	}
-
-	vec, err := s.collection.Get(shared_collection.Key(req.Key), uint(req.Count))
+	vectors, metadata, err := s.collection.Get(shared_collection.Key(req.Key))
 	if err != nil {
-		return nil, err
+		return nil, errorToStatus(err)
 	}
 
-	return &shared_proto_build_collection.GetResponse{Vector: vectorToPB(vec)}, nil
+	return &shared_proto_build_collection.GetResponse{
+		Vectors:  vectorsToPB(vectors),
+		Metadata: metadataToPB(metadata),
+	}, nil
 }
 
 func (s *collectionGrpcServerImplementation) Has(
@@ -187,14 +399,50 @@ func (s *collectionGrpcServerImplementation) Delete(
 
 	return &shared_proto_build_collection.DeleteResponse{
 		Ok: err == nil,
-	}, err
+	}, errorToStatus(err)
+}
+
+func (s *collectionGrpcServerImplementation) DeleteMulti(
+	_ context.Context,
+	req *shared_proto_build_collection.DeleteMultiRequest) (*shared_proto_build_collection.DeleteMultiResponse, error) {
+	if req == nil || len(req.Keys) == 0 {
+		return &shared_proto_build_collection.DeleteMultiResponse{},
+			status.Errorf(codes.InvalidArgument, "request empty or missing arguments")
+	}
+
+	result, err := s.collection.DeleteMulti(*(*[]shared_collection.Key)(unsafe.Pointer(&req.Keys)))
+
+	if errors.Is(err, shared_collection.ErrReadOnly) {
+		return &shared_proto_build_collection.DeleteMultiResponse{}, errorToStatus(err)
+	}
+
+	response := &shared_proto_build_collection.DeleteMultiResponse{
+		Deleted:  result.Deleted,
+		Outcomes: deleteOutcomesToPB(result.Outcomes),
+	}
+
+	if err != nil {
+		var err2 error
+		serr := status.Newf(codes.Internal, "failed to delete keys: %v", err)
+		serr, err2 = serr.WithDetails(response)
+		if err2 != nil {
+			return &shared_proto_build_collection.DeleteMultiResponse{},
+				status.Errorf(
+					codes.Internal,
+					"unable to build response with details when failed to delete keys: %v", err)
+		}
+
+		return &shared_proto_build_collection.DeleteMultiResponse{}, serr.Err()
+	}
+
+	return response, nil
 }
 
 func (s *collectionGrpcServerImplementation) Save(
 	_ context.Context,
 	_ *shared_proto_build_collection.Empty) (*shared_proto_build_collection.Empty, error) {
 	err := s.collection.Save(s.collectionPath)
-	return &shared_proto_build_collection.Empty{}, err
+	return &shared_proto_build_collection.Empty{}, errorToStatus(err)
 }
 
 func (s *collectionGrpcServerImplementation) Length(
@@ -226,3 +474,200 @@ func (s *collectionGrpcServerImplementation) Size(
 	}
 	return &shared_proto_build_collection.SizeResponse{Size: uint64(size)}, nil
 }
+
+func (s *collectionGrpcServerImplementation) Evaluate(
+	_ context.Context,
+	req *shared_proto_build_collection.EvaluateRequest) (*shared_proto_build_collection.EvaluateResponse, error) {
+	if req == nil {
+		return &shared_proto_build_collection.EvaluateResponse{},
+			status.Errorf(codes.InvalidArgument, "request empty or missing arguments")
+	}
+
+	if req.K <= 0 {
+		return &shared_proto_build_collection.EvaluateResponse{},
+			status.Errorf(codes.InvalidArgument, "k must be greater than 0")
+	}
+
+	if len(req.Queries) > shared_collection.EvaluateMaxQueries || req.Sample > shared_collection.EvaluateMaxQueries {
+		return &shared_proto_build_collection.EvaluateResponse{},
+			status.Errorf(
+				codes.InvalidArgument,
+				"queries and sample must be less than or equal to %d",
+				shared_collection.EvaluateMaxQueries)
+	}
+
+	queries := make([]shared_collection.Vector, len(req.Queries))
+	for i, q := range req.Queries {
+		if q == nil || len(q.Values) != int(s.collection.Config.Dimensions) {
+			return &shared_proto_build_collection.EvaluateResponse{},
+				status.Errorf(
+					codes.InvalidArgument,
+					"query %d, expected %d dimensions, got %d",
+					i,
+					s.collection.Config.Dimensions,
+					len(q.GetValues()))
+		}
+
+		queries[i] = q.Values
+	}
+
+	err := s.collection.Config.VectorValidation.Validate("query", queries)
+	if err != nil {
+		return &shared_proto_build_collection.EvaluateResponse{}, errorToStatus(err)
+	}
+
+	// The queries are normalized as the searches are, the sampled vectors already are
+	err = s.normalizer.Apply("query", queries)
+	if err != nil {
+		return &shared_proto_build_collection.EvaluateResponse{}, errorToStatus(err)
+	}
+
+	sample := req.Sample
+	if sample == 0 {
+		sample = evaluateDefaultSample
+	}
+
+	result, err := s.collection.Evaluate(queries, sample, req.K)
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+
+	return evaluationResultToPB(result), nil
+}
+
+func (s *collectionGrpcServerImplementation) SyncStatus(
+	_ context.Context,
+	_ *shared_proto_build_collection.Empty) (*shared_proto_build_collection.SyncStatusResponse, error) {
+	response := &shared_proto_build_collection.SyncStatusResponse{
+		Enabled: s.autoSync != nil,
+		Dirty:   s.collection.IsDirty(),
+	}
+
+	if s.autoSync == nil {
+		return response, nil
+	}
+
+	status := s.autoSync.Status()
+	response.Failures = status.Failures
+	if !status.LastSync.IsZero() {
+		response.LastSyncUnixMillis = status.LastSync.UnixMilli()
+	}
+	if !status.LastFailure.IsZero() {
+		response.LastFailureUnixMillis = status.LastFailure.UnixMilli()
+		response.LastError = status.LastError.Error()
+	}
+
+	return response, nil
+}
+
+func (s *collectionGrpcServerImplementation) NormalizationStatus(
+	_ context.Context,
+	_ *shared_proto_build_collection.Empty) (*shared_proto_build_collection.NormalizationStatusResponse, error) {
+	stats := s.normalizer.Stats()
+
+	return &shared_proto_build_collection.NormalizationStatusResponse{
+		Policy:     stats.Policy.String(),
+		Vectors:    stats.Vectors,
+		Violations: stats.Violations,
+		Normalized: stats.Normalized,
+		Rejected:   stats.Rejected,
+	}, nil
+}
+
+func (s *collectionGrpcServerImplementation) Snapshot(
+	_ context.Context,
+	req *shared_proto_build_collection.SnapshotRequest) (*shared_proto_build_collection.SnapshotInfo, error) {
+	info, err := s.collection.Snapshot(s.collectionPath, req.GetLabel())
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+
+	return snapshotInfoToPB(info), nil
+}
+
+func (s *collectionGrpcServerImplementation) ListSnapshots(
+	_ context.Context,
+	_ *shared_proto_build_collection.Empty) (*shared_proto_build_collection.ListSnapshotsResponse, error) {
+	snapshots, err := s.collection.ListSnapshots(s.collectionPath)
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+
+	response := &shared_proto_build_collection.ListSnapshotsResponse{
+		Snapshots: make([]*shared_proto_build_collection.SnapshotInfo, len(snapshots)),
+	}
+	for i, info := range snapshots {
+		response.Snapshots[i] = snapshotInfoToPB(info)
+	}
+
+	return response, nil
+}
+
+func (s *collectionGrpcServerImplementation) RestoreSnapshot(
+	_ context.Context,
+	req *shared_proto_build_collection.SnapshotRequest) (*shared_proto_build_collection.Empty, error) {
+	err := s.collection.RestoreSnapshot(s.collectionPath, req.GetLabel())
+	return &shared_proto_build_collection.Empty{}, errorToStatus(err)
+}
+
+func (s *collectionGrpcServerImplementation) DeleteSnapshot(
+	_ context.Context,
+	req *shared_proto_build_collection.SnapshotRequest) (*shared_proto_build_collection.Empty, error) {
+	err := s.collection.DeleteSnapshot(s.collectionPath, req.GetLabel())
+	return &shared_proto_build_collection.Empty{}, errorToStatus(err)
+}
+
+func (s *collectionGrpcServerImplementation) Compact(
+	_ context.Context,
+	_ *shared_proto_build_collection.Empty) (*shared_proto_build_collection.CompactResponse, error) {
+	result, err := s.collection.Compact()
+	if err != nil {
+		return nil, errorToStatus(err)
+	}
+
+	return &shared_proto_build_collection.CompactResponse{
+		SizeBefore:     uint64(result.SizeBefore),
+		SizeAfter:      uint64(result.SizeAfter),
+		BytesReclaimed: uint64(result.Reclaimed),
+		IsFull:         result.IsFull,
+	}, nil
+}
+
+func (s *collectionGrpcServerImplementation) Export(
+	req *shared_proto_build_collection.ExportRequest,
+	stream shared_proto_build_collection.Collection_ExportServer) error {
+	if req == nil {
+		return status.Errorf(codes.InvalidArgument, "request empty or missing arguments")
+	}
+
+	pageSize := req.PageSize
+	if pageSize == 0 {
+		pageSize = exportDefaultPageSize
+	}
+
+	if pageSize > shared_collection.ExportMaxPageSize {
+		return status.Errorf(
+			codes.InvalidArgument, "page size must be at most %d", shared_collection.ExportMaxPageSize)
+	}
+
+	pages := s.collection.Export(shared_collection.Key(req.Cursor), shared_collection.ExportOptions{
+		PageSize:        pageSize,
+		IncludeVectors:  req.IncludeVectors,
+		IncludeMetadata: req.IncludeMetadata,
+		IncludeText:     req.IncludeText,
+	})
+
+	for page, err := range pages {
+		if err != nil {
+			return errorToStatus(err)
+		}
+
+		// Stops reading the shard if the client went away
+		err = stream.Send(exportPageToPB(page))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
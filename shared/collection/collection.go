@@ -1,20 +1,54 @@
 package shared_collection
 
 import (
+	"errors"
 	"fmt"
 	usearch "github.com/unum-cloud/usearch/golang"
-	"unsafe"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Key usearch.Key
 
 type Vector []float32
 
+var ErrReadOnly = errors.New("the collection is read-only, the shard is memory-mapped")
+
+// Collection wraps a USearch index and is safe for concurrent use.
+//
+// The read operations (Search, Get, Has, Length, Capacity, Size) share a read lock and run in parallel, the write
+// operations (Add, AddMulti, Delete) and Load/View/Destroy take the write lock and are serialized. The write
+// operations also take writeMutex, which Compact holds while rebuilding the index under the read lock, so the writes
+// wait for the compaction but the reads don't.
+// A collection loaded with View is read-only, the write operations and Save return ErrReadOnly.
+// Save holds writeMutex and the read lock while writing the shard, so reads keep being served but writes wait for it to
+// complete and the shard file is always a consistent snapshot of the index; concurrent calls to Save are serialized.
+// If a write-ahead log has been opened with OpenWAL the writes are recorded to it, under the write lock, before they are
+// applied and the log is truncated by Save.
+//
+// USearch keeps one search context per hardware thread and crashes if more searches than that run at the same time, so
+// the number of concurrent searches is bounded by searchSlots.
 type Collection struct {
-	index   *usearch.Index
-	isFull  bool
-	Config  *CollectionConfig
-	isDirty bool
+	mutex       sync.RWMutex
+	writeMutex  sync.Mutex
+	saveMutex   sync.Mutex
+	index       *usearch.Index
+	isFull      atomic.Bool
+	Config      *CollectionConfig
+	isDirty     atomic.Bool
+	readOnly    atomic.Bool
+	searchSlots chan struct{}
+	keys        keysRegistry
+	metadata    *metadataStore
+	text        *textIndex
+	vectors     *vectorStore
+	createdAt   time.Time
+	checksum    string
+	wal         *writeAheadLog
+	freeSlots   atomic.Uint64
 }
 
 func NewCollection(config *CollectionConfig) (*Collection, error) {
@@ -23,19 +57,206 @@ func NewCollection(config *CollectionConfig) (*Collection, error) {
 		return nil, fmt.Errorf("failed to create index: %w", err)
 	}
 
-	return &Collection{
-		index:  index,
-		Config: config,
-	}, nil
+	c := &Collection{
+		index:       index,
+		Config:      config,
+		searchSlots: make(chan struct{}, runtime.NumCPU()),
+		keys:        make(keysRegistry),
+		metadata:    newMetadataStore(),
+		text:        newTextIndex(),
+		createdAt:   time.Now().UTC(),
+	}
+
+	if config.Rescore {
+		c.vectors = newVectorStore(config.Dimensions, config.TempDir)
+	}
+
+	return c, nil
+}
+
+// lock takes the write lock, the caller must release it with unlock
+func (c *Collection) lock() {
+	c.writeMutex.Lock()
+	c.mutex.Lock()
+}
+
+func (c *Collection) unlock() {
+	c.mutex.Unlock()
+	c.writeMutex.Unlock()
+}
+
+func (c *Collection) acquireSearchSlot() {
+	c.searchSlots <- struct{}{}
+}
+
+func (c *Collection) releaseSearchSlot() {
+	<-c.searchSlots
 }
 
 func (c *Collection) Load(path string) error {
+	return c.load(path, false)
+}
+
+// View memory-maps the shard instead of loading it in memory, the collection becomes read-only and the write
+// operations return ErrReadOnly.
+func (c *Collection) View(path string) error {
+	return c.load(path, true)
+}
+
+func (c *Collection) load(path string, view bool) error {
+	c.lock()
+	defer c.unlock()
+
+	loaded, err := openShard(c.Config, path, view)
+	if err != nil {
+		return err
+	}
+
+	return c.replaceWith(loaded)
+}
+
+// openShard returns a new collection with the content of the shard at path, if any of the files fails to load the
+// error is returned and nothing is kept
+func openShard(config *CollectionConfig, path string, view bool) (*Collection, error) {
+	c, err := NewCollection(config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.loadShard(path, view)
+	if err != nil {
+		_ = c.index.Destroy()
+		_ = c.vectors.close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// replaceWith moves the content of loaded, returned by openShard, to the collection, the caller must hold the write
+// lock. The write-ahead log, if open, is kept.
+func (c *Collection) replaceWith(loaded *Collection) error {
+	err := c.index.Destroy()
+	if err != nil {
+		_ = loaded.index.Destroy()
+		_ = loaded.vectors.close()
+		return fmt.Errorf("failed to destroy index: %w", err)
+	}
+
+	_ = c.vectors.close()
+
+	c.index = loaded.index
+	c.keys = loaded.keys
+	c.metadata = loaded.metadata
+	c.text = loaded.text
+	c.vectors = loaded.vectors
+	c.createdAt = loaded.createdAt
+	c.checksum = loaded.checksum
+	c.freeSlots.Store(loaded.freeSlots.Load())
+	c.readOnly.Store(loaded.readOnly.Load())
+	c.isFull.Store(loaded.isFull.Load())
+	c.isDirty.Store(loaded.isDirty.Load())
+
+	return nil
+}
+
+// loadShard loads the shard at path in the collection, which must have just been created by openShard
+func (c *Collection) loadShard(path string, view bool) error {
 	var size uint
+	var length uint
+	var vectorSize uint
+
+	// Complete the save interrupted by a crash, if any
+	err := RecoverShard(path)
+	if err != nil {
+		return err
+	}
+
+	// Refuse to load the shard if it has been created with different settings, the shards saved before the manifest
+	// was introduced don't have one and are loaded as they are
+	c.freeSlots.Store(0)
+	manifest, err := LoadManifest(path)
+	if err == nil {
+		err = manifest.Validate(c.Config)
+		if err != nil {
+			return err
+		}
+
+		// The checksums are not verified for memory-mapped shards to not read the whole shard when starting
+		if !view {
+			err = manifest.Verify(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.createdAt = manifest.CreatedAt
+		c.checksum = manifest.Checksums.Index
+		c.freeSlots.Store(manifest.FreeSlots)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to load collection manifest: %w", err)
+	}
 
-	err := c.index.Load(path)
+	if view {
+		err = c.index.View(path)
+	} else {
+		err = c.index.Load(path)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to load collection from path: %w", err)
 	}
+	c.readOnly.Store(view)
+
+	// Load the keys registry, if the keys file is missing the keys are tracked only if the index is empty
+	c.keys, err = loadKeysRegistry(keysFilePath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		length, err = c.index.Len()
+		if err != nil {
+			return fmt.Errorf("failed to get length of index: %w", err)
+		}
+
+		if length == 0 {
+			c.keys = make(keysRegistry)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to load collection keys: %w", err)
+	}
+
+	c.metadata, err = loadMetadataStore(metadataFilePath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		c.metadata = newMetadataStore()
+	} else if err != nil {
+		return fmt.Errorf("failed to load collection metadata: %w", err)
+	}
+
+	c.text, err = loadTextIndex(textFilePath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		c.text = newTextIndex()
+	} else if err != nil {
+		return fmt.Errorf("failed to load collection text: %w", err)
+	}
+
+	// The full-precision vectors are required to rescore the searches, the file can only be missing if the index is
+	// empty
+	if c.Config.Rescore {
+		vectors, err := loadVectorStore(vectorsFilePath(path), c.Config.Dimensions, c.Config.TempDir)
+		if errors.Is(err, os.ErrNotExist) {
+			length, err = c.index.Len()
+			if err != nil {
+				return fmt.Errorf("failed to get length of index: %w", err)
+			}
+
+			if length > 0 {
+				return errors.New("rescoring is enabled but the shard has no full-precision vectors file")
+			}
+
+			vectors = newVectorStore(c.Config.Dimensions, c.Config.TempDir)
+		} else if err != nil {
+			return fmt.Errorf("failed to load collection vectors: %w", err)
+		}
+
+		c.vectors = vectors
+	}
 
 	// Get the current size of the index
 	size, err = c.index.SerializedLength()
@@ -43,127 +264,530 @@ func (c *Collection) Load(path string) error {
 		return fmt.Errorf("failed to get size of index: %w", err)
 	}
 
-	if size >= c.Config.MaxSize {
-		c.isFull = true
+	vectorSize, err = c.vectorSerializedLength()
+	if err != nil {
+		return err
 	}
 
-	c.isDirty = false
+	c.isFull.Store(c.headroom(size) < vectorSize)
+	c.isDirty.Store(false)
 
 	return nil
 }
 
 func (c *Collection) IsDirty() bool {
-	return c.isDirty
+	return c.isDirty.Load()
+}
+
+func (c *Collection) IsReadOnly() bool {
+	return c.readOnly.Load()
+}
+
+func (c *Collection) IsFull() bool {
+	return c.isFull.Load()
 }
 
 func (c *Collection) Destroy() error {
+	c.lock()
+	defer c.unlock()
+
 	if c.index == nil {
 		return fmt.Errorf("collection not initialized")
 	}
 
+	if c.wal != nil {
+		err := c.wal.close()
+		if err != nil {
+			return fmt.Errorf("failed to close write-ahead log: %w", err)
+		}
+		c.wal = nil
+	}
+
 	err := c.index.Destroy()
 	if err != nil {
 		return fmt.Errorf("failed to destroy collection: %w", err)
 	}
+	c.index = nil
 
-	return nil
-}
-
-func (c *Collection) Search(query Vector, limit uint32) ([]Key, []float32, error) {
-	keys, distances, err := c.index.Search(query, uint(limit))
-
+	err = c.vectors.close()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to search: %w", err)
+		return fmt.Errorf("failed to close collection vectors: %w", err)
 	}
+	c.vectors = nil
 
-	return *(*[]Key)(unsafe.Pointer(&keys)), distances, nil
+	return nil
+}
+
+// AddResult reports how many vectors have been added, the bytes still available in the shard before reaching the max
+// size, if the shard is full and the outcome of each key.
+type AddResult struct {
+	Inserted uint64
+	Headroom uint
+	IsFull   bool
+	Outcomes []AddOutcome
 }
 
-func (c *Collection) Add(key Key, vector Vector) (uint64, bool, error) {
-	return c.AddMulti([]Key{key}, []Vector{vector})
+func (c *Collection) Add(key Key, vector Vector, metadata Metadata, text string, mode WriteMode) (AddResult, error) {
+	return c.AddMulti([]Key{key}, []Vector{vector}, []Metadata{metadata}, []string{text}, mode)
 }
 
-func (c *Collection) AddMulti(keys []Key, vectors []Vector) (uint64, bool, error) {
+// AddMulti adds the vectors to the index, according to the write mode, until the shard is full.
+//
+// The number of vectors that fit before crossing the max size is calculated in advance using the worst case size of a
+// serialized vector (see vectorSerializedLength), the vectors are then added in one pass and the calculation is
+// repeated on the remaining headroom, as the HNSW upper levels are rarely used the actual size is usually smaller.
+//
+// With WriteModeUpsert the vectors stored under a key before the call are replaced, in multi-vector collections the
+// vectors of a key repeated in the same call are appended.
+//
+// The metadata, if not nil, must have the same length of the keys, the metadata of a key is replaced when its vectors
+// are replaced or when a new vector is added with a non-empty metadata. The texts follow the same rules, they are
+// indexed to be matched by the hybrid searches.
+func (c *Collection) AddMulti(
+	keys []Key,
+	vectors []Vector,
+	metadata []Metadata,
+	texts []string,
+	mode WriteMode) (AddResult, error) {
 	var err error
-	var initialSize uint
-	var finalSize uint
-	inserted := uint64(0)
+	result := AddResult{
+		Outcomes: make([]AddOutcome, len(keys)),
+	}
 
-	// TODO: The mechanism is not efficient at all, if 10000 vectors are added and the reservation triggers a growth
-	//       of the index, only the first vector will be written and the rest will be skipped.
-	//       To avoid wasting too much space, the code that follows, if the max size hasn't been reached, will get the
-	//       current size (initialSize) and then check if the max size threshold has been reached only if the size
-	//       changes again.
-	//       To be efficient it's necessary to calculate in advance the numbers of vectors that can be added before
-	//       reaching the max size (can be done checking capacity) and then know if the next upsize will hit the max
-	//       size, and if so, prevent the addition of the vectors.
-	//       Will be improved in the future.
+	if len(vectors) != len(keys) {
+		return result, fmt.Errorf("expected %d vectors, got %d", len(keys), len(vectors))
+	}
 
-	err = c.index.Reserve(uint(len(keys)))
-	if err != nil {
-		return 0, false, fmt.Errorf("failed to reserve space in index: %w", err)
+	// The vectors are recorded as they are in the write-ahead log, a wrong length would make the log unreadable
+	for i, v := range vectors {
+		if uint(len(v)) != c.Config.Dimensions {
+			return result, fmt.Errorf("vector %d, expected %d dimensions, got %d", i, c.Config.Dimensions, len(v))
+		}
+	}
+
+	if metadata != nil && len(metadata) != len(keys) {
+		return result, fmt.Errorf("expected %d metadata, got %d", len(keys), len(metadata))
+	}
+
+	for i, m := range metadata {
+		if err = m.Validate(); err != nil {
+			return result, fmt.Errorf("invalid metadata %d: %w", i, err)
+		}
+	}
+
+	if texts != nil && len(texts) != len(keys) {
+		return result, fmt.Errorf("expected %d texts, got %d", len(keys), len(texts))
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	if c.readOnly.Load() {
+		return result, ErrReadOnly
+	}
+
+	// Record the call before applying it, so a write seen by the readers is never lost by a crash. Replayed on top of
+	// the same state the call has the same outcomes, a full shard doesn't change and the call isn't recorded.
+	if c.wal != nil && !c.isFull.Load() {
+		err = c.wal.appendAdd(keys, vectors, metadata, texts, mode)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return c.addMulti(keys, vectors, metadata, texts, mode)
+}
+
+// addMulti is AddMulti without the validation of the metadata, the caller must hold the write lock
+func (c *Collection) addMulti(
+	keys []Key,
+	vectors []Vector,
+	metadata []Metadata,
+	texts []string,
+	mode WriteMode) (AddResult, error) {
+	var err error
+	var size uint
+	var length uint
+	var vectorSize uint
+	var count uint64
+	var outcome AddOutcome
+	next := 0
+	replaced := make(map[Key]struct{})
+	result := AddResult{
+		Outcomes: make([]AddOutcome, len(keys)),
 	}
 
-	if c.isFull {
-		return 0, true, nil
+	vectorSize, err = c.vectorSerializedLength()
+	if err != nil {
+		return result, err
 	}
 
 	// Get the current size of the index
-	initialSize, err = c.index.SerializedLength()
+	size, err = c.index.SerializedLength()
 	if err != nil {
-		return 0, false, fmt.Errorf("failed to get size of index: %w", err)
+		return result, fmt.Errorf("failed to get size of index: %w", err)
 	}
 
-	for i, key := range keys {
-		err = c.index.Add(usearch.Key(key), vectors[i])
+	for next < len(keys) && !c.isFull.Load() {
+		// Calculate how many of the remaining vectors can be added without crossing the max size
+		count = min(uint64(len(keys)-next), uint64(c.headroom(size)/vectorSize))
+		if count == 0 {
+			c.isFull.Store(true)
+			break
+		}
+
+		length, err = c.index.Len()
+		if err != nil {
+			return result, fmt.Errorf("failed to get length of index: %w", err)
+		}
+
+		err = c.index.Reserve(length + uint(count))
 		if err != nil {
-			return inserted, false, fmt.Errorf("failed to add vector to index: %w", err)
+			return result, fmt.Errorf("failed to reserve space in index: %w", err)
 		}
 
-		c.isDirty = true
-		inserted++
+		// The keys skipped or already existing don't consume the headroom
+		for ; next < len(keys) && count > 0; next++ {
+			var keyMetadata Metadata
+			if metadata != nil {
+				keyMetadata = metadata[next]
+			}
+
+			var keyText string
+			if texts != nil {
+				keyText = texts[next]
+			}
+
+			outcome, err = c.add(keys[next], vectors[next], keyMetadata, keyText, mode, replaced)
+			if err != nil {
+				return result, err
+			}
+
+			result.Outcomes[next] = outcome
+			if outcome.writes() {
+				result.Inserted++
+				count--
+			}
+		}
 
-		finalSize, err = c.index.SerializedLength()
+		size, err = c.index.SerializedLength()
 		if err != nil {
-			return inserted, false, fmt.Errorf("failed to get size of index: %w", err)
+			return result, fmt.Errorf("failed to get size of index: %w", err)
 		}
-		if initialSize != finalSize && finalSize >= c.Config.MaxSize {
-			c.isFull = true
-			break
+	}
+
+	// Mark the shard as full if there isn't enough space for another vector
+	if c.headroom(size) < vectorSize {
+		c.isFull.Store(true)
+	}
+
+	result.Headroom = c.headroom(size)
+	result.IsFull = c.isFull.Load()
+
+	return result, nil
+}
+
+// add writes the vector according to the write mode, the caller must hold the write lock and must have reserved the
+// space in the index, replaced tracks the keys already replaced in the current call.
+func (c *Collection) add(
+	key Key,
+	vector Vector,
+	metadata Metadata,
+	text string,
+	mode WriteMode,
+	replaced map[Key]struct{}) (AddOutcome, error) {
+	outcome := AddOutcomeInserted
+
+	exists, err := c.index.Contains(usearch.Key(key))
+	if err != nil {
+		return AddOutcomeNotProcessed, fmt.Errorf("failed to check if the key exists in the index: %w", err)
+	}
+
+	if exists {
+		_, alreadyReplaced := replaced[key]
+
+		switch {
+		case mode == WriteModeSkipIfExists:
+			return AddOutcomeSkipped, nil
+		case mode == WriteModeInsert && !c.Config.Multi:
+			return AddOutcomeAlreadyExists, nil
+		case mode == WriteModeUpsert && (!c.Config.Multi || !alreadyReplaced):
+			_, err = c.removeFromIndex(key)
+			if err != nil {
+				return AddOutcomeNotProcessed, err
+			}
+
+			outcome = AddOutcomeReplaced
 		}
 	}
 
-	return inserted, c.isFull, nil
+	location, err := c.vectors.write(vector)
+	if err != nil {
+		return AddOutcomeNotProcessed, err
+	}
+
+	err = c.index.Add(usearch.Key(key), vector)
+	if err != nil {
+		return AddOutcomeNotProcessed, fmt.Errorf("failed to add vector to index: %w", err)
+	}
+
+	c.vectors.add(key, location)
+
+	// USearch stores the new vector in a slot freed by a removal, if any
+	if c.freeSlots.Load() > 0 {
+		c.freeSlots.Add(^uint64(0))
+	}
+
+	if mode == WriteModeUpsert {
+		replaced[key] = struct{}{}
+	}
+
+	if outcome == AddOutcomeReplaced || len(metadata) > 0 {
+		c.metadata.set(key, metadata)
+	}
+
+	if outcome == AddOutcomeReplaced || text != "" {
+		c.text.set(key, text)
+	}
+
+	c.keys.add(key)
+	c.isDirty.Store(true)
+
+	return outcome, nil
+}
+
+// saveIndex saves the index to path and checks that the file can be read back, the caller must hold the lock
+func (c *Collection) saveIndex(path string) error {
+	err := c.index.Save(path)
+	if err != nil {
+		return err
+	}
+
+	size, err := c.index.SerializedLength()
+	if err != nil {
+		return fmt.Errorf("failed to get size of index: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to verify saved index: %w", err)
+	}
+
+	if uint(info.Size()) != size {
+		return fmt.Errorf("failed to verify saved index: expected %d bytes, found %d", size, info.Size())
+	}
+
+	config, err := usearch.Metadata(path)
+	if err != nil {
+		return fmt.Errorf("failed to verify saved index: %w", err)
+	}
+
+	if config.Dimensions != c.Config.Dimensions {
+		return fmt.Errorf(
+			"failed to verify saved index: expected %d dimensions, found %d",
+			c.Config.Dimensions,
+			config.Dimensions)
+	}
+
+	return nil
+}
+
+func (c *Collection) headroom(size uint) uint {
+	if size >= c.Config.MaxSize {
+		return 0
+	}
+
+	return c.Config.MaxSize - size
+}
+
+// vectorSerializedLength returns the upper bound of the bytes needed to serialize a vector in the index: the vector
+// itself, its key and level, the neighbors of the base level and the neighbors of one upper level of the HNSW graph.
+func (c *Collection) vectorSerializedLength() (uint, error) {
+	const keyBytes = 8
+	const levelBytes = 2
+	const neighborsCountBytes = 4
+	const neighborBytes = 4
+
+	connectivity, err := c.index.Connectivity()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connectivity of index: %w", err)
+	}
+
+	// The level is stored twice, in the levels list and in the node itself
+	nodeBytes := uint(keyBytes + levelBytes*2)
+	baseLevelBytes := neighborsCountBytes + neighborBytes*connectivity*2
+	upperLevelBytes := neighborsCountBytes + neighborBytes*connectivity
+
+	return c.Config.vectorBytes() + nodeBytes + baseLevelBytes + upperLevelBytes, nil
 }
 
-func (c *Collection) Get(key Key, count uint) (Vector, error) {
-	vector, err := c.index.Get(usearch.Key(key), count)
+// Get returns the vectors and the metadata stored under the key, for multi-vector collections all the vectors of the key
+// are returned.
+func (c *Collection) Get(key Key) ([]Vector, Metadata, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var count uint
+	var err error
+	if c.keys != nil {
+		count = uint(c.keys.count(key))
+	} else {
+		count, err = usearchCount(c.index, key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to count vectors in index: %w", err)
+		}
+	}
+
+	vectors, err := usearchGet(c.index, key, count, c.Config.Dimensions)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get vector from index: %w", err)
+		return nil, nil, fmt.Errorf("failed to get vector from index: %w", err)
+	}
+
+	if len(vectors) == 0 {
+		return nil, nil, nil
 	}
 
-	return vector, nil
+	return vectors, c.metadata.get(key), nil
 }
 
 func (c *Collection) Has(key Key) bool {
-	_, err := c.index.Get(usearch.Key(key), 1)
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	found, err := c.index.Contains(usearch.Key(key))
 	if err != nil {
 		return false
 	}
 
-	return true
+	return found
 }
 
 func (c *Collection) Delete(key Key) error {
-	err := c.index.Remove(usearch.Key(key))
+	c.lock()
+	defer c.unlock()
+
+	if c.readOnly.Load() {
+		return ErrReadOnly
+	}
+
+	if c.wal != nil {
+		err := c.wal.appendDelete(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.delete(key)
+}
+
+// delete removes the vectors of the key, the caller must hold the write lock
+func (c *Collection) delete(key Key) error {
+	_, err := c.removeFromIndex(key)
 	if err != nil {
-		return fmt.Errorf("failed to delete vector from index: %w", err)
+		return err
 	}
 
+	c.metadata.remove(key)
+	c.text.remove(key)
+	c.isDirty.Store(true)
+
 	return nil
 }
 
+// DeleteResult reports how many keys have been deleted and the outcome of each key
+type DeleteResult struct {
+	Deleted  uint64
+	Outcomes []DeleteOutcome
+}
+
+// DeleteMulti removes the vectors of the keys holding the write lock once, a key that fails to be removed is reported
+// as failed and doesn't stop the deletion of the others. The error is returned only if the whole call failed.
+func (c *Collection) DeleteMulti(keys []Key) (DeleteResult, error) {
+	c.lock()
+	defer c.unlock()
+
+	if c.readOnly.Load() {
+		return DeleteResult{Outcomes: make([]DeleteOutcome, len(keys))}, ErrReadOnly
+	}
+
+	// Record the keys stored before deleting them, the others don't change the collection when the call is replayed
+	if c.wal != nil {
+		stored := make([]Key, 0, len(keys))
+		for _, key := range keys {
+			if found, err := c.index.Contains(usearch.Key(key)); err != nil || found {
+				stored = append(stored, key)
+			}
+		}
+
+		if len(stored) > 0 {
+			err := c.wal.appendDeleteMulti(stored)
+			if err != nil {
+				return DeleteResult{Outcomes: make([]DeleteOutcome, len(keys))}, err
+			}
+		}
+	}
+
+	return c.deleteMulti(keys), nil
+}
+
+// deleteMulti removes the vectors of the keys, the caller must hold the write lock
+func (c *Collection) deleteMulti(keys []Key) DeleteResult {
+	result := DeleteResult{
+		Outcomes: make([]DeleteOutcome, len(keys)),
+	}
+
+	for i, key := range keys {
+		removed, err := c.removeFromIndex(key)
+		switch {
+		case err != nil:
+			result.Outcomes[i] = DeleteOutcomeFailed
+		case removed == 0:
+			result.Outcomes[i] = DeleteOutcomeMissing
+		default:
+			c.metadata.remove(key)
+			c.text.remove(key)
+			result.Outcomes[i] = DeleteOutcomeDeleted
+			result.Deleted++
+		}
+	}
+
+	if result.Deleted > 0 {
+		c.isDirty.Store(true)
+	}
+
+	return result
+}
+
+// removeFromIndex removes the vectors of the key from the index and from the keys registry, USearch doesn't release
+// the slots of the removed vectors, they are reused by the next additions or released by Compact.
+// It returns the number of vectors removed, the caller must hold the write lock.
+func (c *Collection) removeFromIndex(key Key) (uint, error) {
+	before, err := c.index.Len()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get length of index: %w", err)
+	}
+
+	err = c.index.Remove(usearch.Key(key))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete vector from index: %w", err)
+	}
+
+	after, err := c.index.Len()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get length of index: %w", err)
+	}
+
+	c.keys.remove(key)
+	c.vectors.remove(key)
+	c.freeSlots.Add(uint64(before - after))
+
+	return before - after, nil
+}
+
 func (c *Collection) Length() (uint, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
 	length, err := c.index.Len()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get length of index: %w", err)
@@ -173,6 +797,9 @@ func (c *Collection) Length() (uint, error) {
 }
 
 func (c *Collection) Capacity() (uint, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
 	capacity, err := c.index.Capacity()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get capacity of index: %w", err)
@@ -182,6 +809,9 @@ func (c *Collection) Capacity() (uint, error) {
 }
 
 func (c *Collection) Size() (uint, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
 	size, err := c.index.SerializedLength()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get size of index: %w", err)
@@ -191,13 +821,126 @@ func (c *Collection) Size() (uint, error) {
 }
 
 func (c *Collection) Save(path string) error {
-	err := c.index.Save(path)
+	// Serialize the saves and hold the read lock to block the writers while the shard is written
+	c.saveMutex.Lock()
+	defer c.saveMutex.Unlock()
+
+	// The writes wait on writeMutex, a writer waiting for the write lock would block the new reads until the save ends
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.readOnly.Load() {
+		return ErrReadOnly
+	}
 
+	return c.save(path)
+}
+
+// save writes the shard to path, the caller must hold saveMutex and either the read or the write lock
+func (c *Collection) save(path string) error {
+	manifest, err := c.writeShard(path)
 	if err != nil {
-		return fmt.Errorf("failed to save index: %w", err)
+		return err
+	}
+	c.checksum = manifest.Checksums.Index
+
+	// The writes recorded in the write-ahead log are now in the shard
+	if c.wal != nil {
+		err = c.wal.truncate(c.checksum)
+		if err != nil {
+			return fmt.Errorf("failed to truncate write-ahead log: %w", err)
+		}
 	}
 
-	c.isDirty = false
+	c.isDirty.Store(false)
 
 	return nil
 }
+
+// writeShard writes the index, its sidecar files and the manifest to path and returns the manifest, the caller must
+// hold saveMutex and either the read or the write lock
+func (c *Collection) writeShard(path string) (*Manifest, error) {
+	// Write all the files of the shard to temporary files, the save is committed by renaming the manifest, which
+	// contains the checksums of the others, to the commit path. Only then the files are renamed over the old ones, if
+	// the process crashes before the commit the old shard is kept, after it RecoverShard completes the save.
+	var staged []*stagedFile
+	defer func() {
+		for _, f := range staged {
+			f.discard()
+		}
+	}()
+
+	err := RecoverShard(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := c.manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	indexFile, err := stageFile(path, c.saveIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save index: %w", err)
+	}
+	staged = append(staged, indexFile)
+	manifest.Checksums.Index = indexFile.checksum
+
+	if c.keys != nil {
+		keysFile, err := stageFile(keysFilePath(path), c.keys.save)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save collection keys: %w", err)
+		}
+		staged = append(staged, keysFile)
+		manifest.Checksums.Keys = keysFile.checksum
+	}
+
+	metadataFile, err := stageFile(metadataFilePath(path), c.metadata.save)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save collection metadata: %w", err)
+	}
+	staged = append(staged, metadataFile)
+	manifest.Checksums.Metadata = metadataFile.checksum
+
+	textFile, err := stageFile(textFilePath(path), c.text.save)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save collection text: %w", err)
+	}
+	staged = append(staged, textFile)
+	manifest.Checksums.Text = textFile.checksum
+
+	if c.vectors != nil {
+		vectorsFile, err := stageFile(vectorsFilePath(path), c.vectors.save)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save collection vectors: %w", err)
+		}
+		staged = append(staged, vectorsFile)
+		manifest.Checksums.Vectors = vectorsFile.checksum
+	}
+
+	manifestFile, err := stageFile(manifestFilePath(path), manifest.save)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save collection manifest: %w", err)
+	}
+	staged = append(staged, manifestFile)
+
+	err = syncDir(path)
+	if err == nil {
+		err = rename(manifestFile.tmpPath, commitFilePath(path))
+	}
+	if err == nil {
+		staged = nil
+		err = syncDir(path)
+	}
+	if err == nil {
+		err = completeCommit(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save collection: %w", err)
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,191 @@
+package shared_collection
+
+import (
+	"fmt"
+	usearch "github.com/unum-cloud/usearch/golang"
+)
+
+// CompactResult reports the serialized size of the index before and after the compaction and if the shard is still
+// full, Reclaimed is the difference between the two sizes.
+type CompactResult struct {
+	SizeBefore uint
+	SizeAfter  uint
+	Reclaimed  uint
+	IsFull     bool
+}
+
+// FreeSlots returns the number of slots of removed vectors not reused yet
+func (c *Collection) FreeSlots() uint64 {
+	return c.freeSlots.Load()
+}
+
+// Compact rebuilds the index with only the stored vectors to release the slots of the removed ones, which USearch
+// keeps in the serialized shard, and recalculates if the shard is full. The spill file of the full-precision vectors,
+// if stored, is rewritten as well. The collection is marked as dirty as the
+// shard has to be saved to shrink.
+// The rebuild holds the read lock and writeMutex, the searches keep being served and the writes wait for it to complete,
+// the write lock is taken only to swap the indexes.
+func (c *Collection) Compact() (CompactResult, error) {
+	var result CompactResult
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	index, vectors, size, err := c.buildCompactedIndex()
+	if err != nil {
+		return result, err
+	}
+	result.SizeBefore = size
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err = c.index.Destroy()
+	if err != nil {
+		_ = index.Destroy()
+		_ = vectors.close()
+		return result, fmt.Errorf("failed to destroy index: %w", err)
+	}
+	c.index = index
+
+	if c.vectors != nil {
+		_ = c.vectors.close()
+		c.vectors = vectors
+	}
+	c.freeSlots.Store(0)
+	c.isDirty.Store(true)
+
+	size, err = c.index.SerializedLength()
+	if err != nil {
+		return result, fmt.Errorf("failed to get size of index: %w", err)
+	}
+	result.SizeAfter = size
+	if result.SizeBefore > result.SizeAfter {
+		result.Reclaimed = result.SizeBefore - result.SizeAfter
+	}
+
+	vectorSize, err := c.vectorSerializedLength()
+	if err != nil {
+		return result, err
+	}
+
+	c.isFull.Store(c.headroom(size) < vectorSize)
+	result.IsFull = c.isFull.Load()
+
+	return result, nil
+}
+
+// buildCompactedIndex rebuilds, under the read lock, the index and the full-precision vectors store, if any, and
+// returns them with the size of the current index, the caller must hold writeMutex
+func (c *Collection) buildCompactedIndex() (*usearch.Index, *vectorStore, uint, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.readOnly.Load() {
+		return nil, nil, 0, ErrReadOnly
+	}
+
+	// The vectors are copied key by key, without the keys they can't be enumerated
+	if c.keys == nil {
+		return nil, nil, 0, ErrKeysNotTracked
+	}
+
+	size, err := c.index.SerializedLength()
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to get size of index: %w", err)
+	}
+
+	var vectors *vectorStore
+	if c.vectors != nil {
+		vectors, err = c.vectors.compacted()
+		if err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	index, err := c.rebuildIndex()
+	if err != nil {
+		_ = vectors.close()
+		return nil, nil, 0, err
+	}
+
+	return index, vectors, size, nil
+}
+
+// rebuildIndex returns a new index, with the settings of the current one, containing all the vectors of the tracked
+// keys, the caller must hold the read lock and writeMutex
+func (c *Collection) rebuildIndex() (*usearch.Index, error) {
+	var err error
+
+	config := c.Config.toUsearchConfig()
+	if config.Connectivity, err = c.index.Connectivity(); err != nil {
+		return nil, fmt.Errorf("failed to get connectivity of index: %w", err)
+	}
+
+	if config.ExpansionAdd, err = c.index.ExpansionAdd(); err != nil {
+		return nil, fmt.Errorf("failed to get expansion add of index: %w", err)
+	}
+
+	if config.ExpansionSearch, err = c.index.ExpansionSearch(); err != nil {
+		return nil, fmt.Errorf("failed to get expansion search of index: %w", err)
+	}
+
+	length, err := c.index.Len()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get length of index: %w", err)
+	}
+
+	index, err := usearch.NewIndex(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index: %w", err)
+	}
+
+	err = c.copyVectors(index, length)
+	if err != nil {
+		_ = index.Destroy()
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// copyVectors adds the vectors of the tracked keys to the index, the full-precision vectors are copied if stored as the
+// ones in the index have been quantized
+func (c *Collection) copyVectors(index *usearch.Index, length uint) error {
+	err := index.Reserve(length)
+	if err != nil {
+		return fmt.Errorf("failed to reserve space in index: %w", err)
+	}
+
+	for key, count := range c.keys {
+		if c.vectors != nil {
+			vectors, err := c.vectors.get(key)
+			if err != nil {
+				return err
+			}
+
+			for _, vector := range vectors {
+				err = index.Add(usearch.Key(key), vector)
+				if err != nil {
+					return fmt.Errorf("failed to add vector to index: %w", err)
+				}
+			}
+
+			continue
+		}
+
+		values, err := c.index.Get(usearch.Key(key), uint(count))
+		if err != nil {
+			return fmt.Errorf("failed to get vector from index: %w", err)
+		}
+
+		for i := uint(0); i < uint(len(values))/c.Config.Dimensions; i++ {
+			err = index.Add(usearch.Key(key), values[i*c.Config.Dimensions:(i+1)*c.Config.Dimensions])
+			if err != nil {
+				return fmt.Errorf("failed to add vector to index: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
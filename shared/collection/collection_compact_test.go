@@ -0,0 +1,116 @@
+package shared_collection
+
+import (
+	"sync"
+	"testing"
+)
+
+// fillTestCollection adds keys, starting from 1, until the collection is full and returns the number of keys added
+func fillTestCollection(t *testing.T, c *Collection) Key {
+	t.Helper()
+
+	key := Key(0)
+	for !c.IsFull() {
+		key++
+		result, err := c.Add(key, Vector{float32(key), 0, 0}, nil, "", WriteModeInsert)
+		if err != nil {
+			t.Fatalf("failed to add key %d: %v", key, err)
+		}
+		if result.Inserted == 0 {
+			key--
+		}
+	}
+
+	return key
+}
+
+func TestCompact(t *testing.T) {
+	c := newTestCollection(t, func(config *CollectionConfig) { config.MaxSize = 64 << 10 })
+	keys := fillTestCollection(t, c)
+
+	for key := Key(1); key <= keys; key += 2 {
+		if err := c.Delete(key); err != nil {
+			t.Fatalf("failed to delete key %d: %v", key, err)
+		}
+	}
+	if c.FreeSlots() != uint64((keys+1)/2) || !c.IsFull() {
+		t.Fatalf("expected %d free slots in a full shard, got %d, full %t", (keys+1)/2, c.FreeSlots(), c.IsFull())
+	}
+
+	result, err := c.Compact()
+	if err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+
+	if c.FreeSlots() != 0 || c.IsFull() || result.IsFull {
+		t.Errorf("expected no free slots in a shard not full, got %d, full %t", c.FreeSlots(), c.IsFull())
+	}
+	if result.Reclaimed == 0 || result.SizeAfter >= result.SizeBefore {
+		t.Errorf("expected the size to shrink, got %+v", result)
+	}
+	if !c.IsDirty() {
+		t.Error("expected the compacted collection to be dirty")
+	}
+
+	for key := Key(1); key <= keys; key++ {
+		if c.Has(key) != (key%2 == 0) {
+			t.Errorf("expected only the even keys, key %d found %t", key, c.Has(key))
+		}
+	}
+
+	// The slots released can be used by new vectors
+	if _, err := c.Add(keys+1, Vector{float32(keys + 1), 0, 0}, nil, "", WriteModeInsert); err != nil || !c.Has(keys+1) {
+		t.Errorf("expected key %d to be added, got %v", keys+1, err)
+	}
+}
+
+func TestCompactServesReads(t *testing.T) {
+	const keys = 2000
+
+	c := newTestCollection(t, nil)
+	for key := Key(1); key <= keys; key++ {
+		if _, err := c.Add(key, Vector{float32(key), 0, 0}, nil, "", WriteModeInsert); err != nil {
+			t.Fatalf("failed to add key %d: %v", key, err)
+		}
+	}
+	for key := Key(1); key <= keys; key += 2 {
+		if err := c.Delete(key); err != nil {
+			t.Fatalf("failed to delete key %d: %v", key, err)
+		}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for reader := 0; reader < 4; reader++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Each reader searches at least once, before, during or after the swap of the indexes
+			for key := Key(2); ; key = key%keys + 2 {
+				result, err := c.Search(Vector{float32(key), 0, 0}, 1, nil)
+				if err != nil {
+					t.Errorf("failed to search: %v", err)
+					return
+				}
+				if len(result.Keys) != 1 || result.Keys[0] != key {
+					t.Errorf("expected key %d, got %v", key, result.Keys)
+					return
+				}
+
+				select {
+				case <-done:
+					return
+				default:
+				}
+			}
+		}()
+	}
+
+	_, err := c.Compact()
+	close(done)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+}
@@ -75,26 +75,97 @@ func ParseMetric(metric string) (Metric, error) {
 	}
 }
 
+// String returns the name of the quantization as accepted by ParseQuantization
+func (q Quantization) String() string {
+	switch q {
+	case F32:
+		return "f32"
+	case BF16:
+		return "bf16"
+	case F16:
+		return "f16"
+	case F64:
+		return "f64"
+	case I8:
+		return "i8"
+	case B1:
+		return "b1"
+	default:
+		return fmt.Sprintf("unknown(%d)", q)
+	}
+}
+
+// String returns the name of the metric as accepted by ParseMetric
+func (m Metric) String() string {
+	switch m {
+	case InnerProduct:
+		return "innerproduct"
+	case Cosine:
+		return "cosine"
+	case L2sq:
+		return "l2sq"
+	case Haversine:
+		return "haversine"
+	case Divergence:
+		return "divergence"
+	case Pearson:
+		return "pearson"
+	case Hamming:
+		return "hamming"
+	case Tanimoto:
+		return "tanimoto"
+	case Sorensen:
+		return "sorensen"
+	default:
+		return fmt.Sprintf("unknown(%d)", m)
+	}
+}
+
+// CollectionConfig is the configuration of the index, if Rescore is set a full-precision copy of the vectors is stored
+// next to the index to rescore the searches of the quantized indexes.
+// Normalization and VectorValidation are the policies the servers enforce on the vectors written and searched, they
+// aren't stored in the shard. TempDir is the directory of the temporary files, the default one of the OS if empty.
 type CollectionConfig struct {
-	Quantization    Quantization
-	Metric          Metric
-	Dimensions      uint
-	Connectivity    uint
-	ExpansionAdd    uint
-	ExpansionSearch uint
-	Multi           bool
-	MaxSize         uint
+	Quantization     Quantization
+	Metric           Metric
+	Dimensions       uint
+	Connectivity     uint
+	ExpansionAdd     uint
+	ExpansionSearch  uint
+	Multi            bool
+	MaxSize          uint
+	Rescore          bool
+	Normalization    NormalizationPolicy
+	VectorValidation VectorValidation
+	TempDir          string
 }
 
 func NewCollectionConfig() *CollectionConfig {
 	return &CollectionConfig{
-		Quantization:    F32,
-		Metric:          Cosine,
-		Dimensions:      0,
-		Connectivity:    0,
-		ExpansionAdd:    0,
-		ExpansionSearch: 0,
-		Multi:           false,
+		Quantization:     F32,
+		Metric:           Cosine,
+		Dimensions:       0,
+		Connectivity:     0,
+		ExpansionAdd:     0,
+		ExpansionSearch:  0,
+		Multi:            false,
+		VectorValidation: DefaultVectorValidation(Cosine),
+	}
+}
+
+// vectorBytes returns the bytes used to store a vector in the index with the configured quantization
+func (c *CollectionConfig) vectorBytes() uint {
+	switch c.Quantization {
+	case F64:
+		return c.Dimensions * 8
+	case BF16, F16:
+		return c.Dimensions * 2
+	case I8:
+		return c.Dimensions
+	case B1:
+		return (c.Dimensions + 7) / 8
+	default:
+		return c.Dimensions * 4
 	}
 }
 
@@ -106,5 +177,6 @@ func (c *CollectionConfig) toUsearchConfig() usearch.IndexConfig {
 		Connectivity:    c.Connectivity,
 		ExpansionAdd:    c.ExpansionAdd,
 		ExpansionSearch: c.ExpansionSearch,
+		Multi:           c.Multi,
 	}
 }
@@ -0,0 +1,212 @@
+package shared_collection
+
+import (
+	"errors"
+	"fmt"
+	usearch "github.com/unum-cloud/usearch/golang"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// EvaluateMaxQueries bounds the queries, provided or sampled, of an evaluation, each one scans the whole shard
+const EvaluateMaxQueries = 1000
+
+var ErrNoQueries = errors.New("no queries to evaluate, the shard is empty")
+
+// EvaluationResult reports how the approximate search performs compared to the exact search
+type EvaluationResult struct {
+	Queries            uint32
+	K                  uint32
+	Recall             float64
+	ApproximateLatency LatencyPercentiles
+	ExactLatency       LatencyPercentiles
+	Settings           EvaluationSettings
+}
+
+type LatencyPercentiles struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// EvaluationSettings are the settings of the index used for the evaluation
+type EvaluationSettings struct {
+	Dimensions      uint
+	Metric          string
+	Quantization    string
+	Connectivity    uint
+	ExpansionAdd    uint
+	ExpansionSearch uint
+	Multi           bool
+	Length          uint
+}
+
+// Evaluate runs every query with both the approximate and the exact search and reports the mean recall@k and the
+// latencies, if no queries are provided sample vectors stored in the shard are used as queries. Up to
+// EvaluateMaxQueries queries are run, the read lock is taken for each query so the writes are not stalled by the whole
+// evaluation.
+func (c *Collection) Evaluate(queries []Vector, sample uint32, k uint32) (EvaluationResult, error) {
+	var err error
+
+	result := EvaluationResult{K: k}
+
+	if k == 0 {
+		return result, errors.New("k must be greater than 0")
+	}
+
+	if len(queries) > EvaluateMaxQueries {
+		return result, fmt.Errorf("expected at most %d queries, got %d", EvaluateMaxQueries, len(queries))
+	}
+
+	c.mutex.RLock()
+	result.Settings, err = c.evaluationSettings()
+	if err == nil && len(queries) == 0 {
+		queries, err = c.sampleVectors(min(sample, EvaluateMaxQueries))
+	}
+	c.mutex.RUnlock()
+
+	if err != nil {
+		return result, err
+	}
+
+	if len(queries) == 0 {
+		return result, ErrNoQueries
+	}
+
+	approximateLatencies := make([]time.Duration, len(queries))
+	exactLatencies := make([]time.Duration, len(queries))
+	recallSum := float64(0)
+
+	for i, query := range queries {
+		queryRecall, approximateLatency, exactLatency, err := c.evaluateQuery(query, k)
+		if err != nil {
+			return result, fmt.Errorf("query %d: %w", i, err)
+		}
+
+		recallSum += queryRecall
+		approximateLatencies[i] = approximateLatency
+		exactLatencies[i] = exactLatency
+	}
+
+	result.Queries = uint32(len(queries))
+	result.Recall = recallSum / float64(len(queries))
+	result.ApproximateLatency = latencyPercentiles(approximateLatencies)
+	result.ExactLatency = latencyPercentiles(exactLatencies)
+
+	return result, nil
+}
+
+// evaluateQuery runs the query with both the approximate and the exact search holding the read lock, so both see the
+// same content of the shard, and returns the recall@k and the latencies
+func (c *Collection) evaluateQuery(query Vector, k uint32) (float64, time.Duration, time.Duration, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	start := time.Now()
+	approximate, err := c.search(query, k, nil)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	approximateLatency := time.Since(start)
+
+	start = time.Now()
+	exact, err := c.search(query, k, &SearchOptions{Exact: true})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	exactLatency := time.Since(start)
+
+	return recall(approximate.Keys, exact.Keys), approximateLatency, exactLatency, nil
+}
+
+func (c *Collection) evaluationSettings() (EvaluationSettings, error) {
+	var err error
+
+	settings := EvaluationSettings{
+		Dimensions:   c.Config.Dimensions,
+		Metric:       c.Config.Metric.String(),
+		Quantization: c.Config.Quantization.String(),
+		Multi:        c.Config.Multi,
+	}
+
+	if settings.Connectivity, err = c.index.Connectivity(); err != nil {
+		return settings, fmt.Errorf("failed to get connectivity of index: %w", err)
+	}
+
+	if settings.ExpansionAdd, err = c.index.ExpansionAdd(); err != nil {
+		return settings, fmt.Errorf("failed to get expansion add of index: %w", err)
+	}
+
+	if settings.ExpansionSearch, err = c.index.ExpansionSearch(); err != nil {
+		return settings, fmt.Errorf("failed to get expansion search of index: %w", err)
+	}
+
+	if settings.Length, err = c.index.Len(); err != nil {
+		return settings, fmt.Errorf("failed to get length of index: %w", err)
+	}
+
+	return settings, nil
+}
+
+// sampleVectors returns the first vector of up to count random keys, the caller must hold the read lock
+func (c *Collection) sampleVectors(count uint32) ([]Vector, error) {
+	if c.keys == nil {
+		return nil, ErrKeysNotTracked
+	}
+
+	keys := make([]Key, 0, len(c.keys))
+	for key := range c.keys {
+		keys = append(keys, key)
+	}
+	rand.Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+	keys = keys[:min(len(keys), int(count))]
+
+	vectors := make([]Vector, 0, len(keys))
+	for _, key := range keys {
+		values, err := c.index.Get(usearch.Key(key), 1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get vector from index: %w", err)
+		}
+
+		if len(values) > 0 {
+			vectors = append(vectors, values[:c.Config.Dimensions])
+		}
+	}
+
+	return vectors, nil
+}
+
+// recall returns the fraction of the exact keys found by the approximate search, 1 if there are no exact keys
+func recall(approximate []Key, exact []Key) float64 {
+	if len(exact) == 0 {
+		return 1
+	}
+
+	found := 0
+	for _, key := range approximate {
+		if slices.Contains(exact, key) {
+			found++
+		}
+	}
+
+	return float64(found) / float64(len(exact))
+}
+
+func latencyPercentiles(latencies []time.Duration) LatencyPercentiles {
+	slices.Sort(latencies)
+
+	percentile := func(p float64) time.Duration {
+		return latencies[min(len(latencies)-1, int(p*float64(len(latencies))))]
+	}
+
+	return LatencyPercentiles{
+		P50: percentile(0.50),
+		P90: percentile(0.90),
+		P99: percentile(0.99),
+		Max: latencies[len(latencies)-1],
+	}
+}
@@ -0,0 +1,143 @@
+package shared_collection
+
+import (
+	"fmt"
+	usearch "github.com/unum-cloud/usearch/golang"
+	"iter"
+	"maps"
+	"math"
+	"slices"
+)
+
+const ExportMaxPageSize = 10000
+
+// ExportOptions select the page size and what is exported along with the keys
+type ExportOptions struct {
+	PageSize        uint32
+	IncludeVectors  bool
+	IncludeMetadata bool
+	IncludeText     bool
+}
+
+type ExportEntry struct {
+	Key      Key
+	Vectors  []Vector
+	Metadata Metadata
+	Text     string
+}
+
+// ExportPage is a page of entries sorted by key, the export can be resumed from NextCursor, Done is true for the last
+// page.
+type ExportPage struct {
+	Entries    []ExportEntry
+	NextCursor Key
+	Done       bool
+}
+
+// Export iterates in pages, sorted by key, the keys greater than or equal to the cursor. The keys are collected when
+// the iteration starts and each page is read holding the read lock, the keys deleted in the meantime are skipped and the
+// keys added are exported only if they are included in a following export.
+func (c *Collection) Export(cursor Key, options ExportOptions) iter.Seq2[ExportPage, error] {
+	return func(yield func(ExportPage, error) bool) {
+		if options.PageSize == 0 || options.PageSize > ExportMaxPageSize {
+			yield(ExportPage{}, fmt.Errorf("page size must be between 1 and %d", ExportMaxPageSize))
+			return
+		}
+
+		keys, err := c.exportKeys(cursor)
+		if err != nil {
+			yield(ExportPage{}, err)
+			return
+		}
+
+		// An empty shard, or a cursor past the last key, still gets a page to report the end of the export
+		for start := 0; start == 0 || start < len(keys); start += int(options.PageSize) {
+			end := min(start+int(options.PageSize), len(keys))
+
+			page, err := c.exportPage(keys[start:end], options)
+			if err != nil {
+				yield(ExportPage{}, err)
+				return
+			}
+
+			page.Done = end == len(keys)
+			page.NextCursor = cursor
+			if end > 0 {
+				page.NextCursor = keys[end-1] + 1
+				// The last key has no following cursor
+				if keys[end-1] == Key(math.MaxUint64) {
+					page.Done = true
+				}
+			}
+
+			if !yield(page, nil) || page.Done {
+				return
+			}
+		}
+	}
+}
+
+// exportKeys returns the sorted keys greater than or equal to the cursor
+func (c *Collection) exportKeys(cursor Key) ([]Key, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.keys == nil {
+		return nil, ErrKeysNotTracked
+	}
+
+	keys := slices.Sorted(maps.Keys(c.keys))
+	start, _ := slices.BinarySearch(keys, cursor)
+
+	return keys[start:], nil
+}
+
+func (c *Collection) exportPage(keys []Key, options ExportOptions) (ExportPage, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	page := ExportPage{
+		Entries: make([]ExportEntry, 0, len(keys)),
+	}
+
+	for _, key := range keys {
+		count := c.keys.count(key)
+		if count == 0 {
+			continue
+		}
+
+		entry := ExportEntry{Key: key}
+
+		// The vectors in the index are quantized, the full-precision ones are exported if stored
+		if options.IncludeVectors && c.vectors != nil {
+			vectors, err := c.vectors.get(key)
+			if err != nil {
+				return page, err
+			}
+
+			entry.Vectors = vectors
+		} else if options.IncludeVectors {
+			values, err := c.index.Get(usearch.Key(key), uint(count))
+			if err != nil {
+				return page, fmt.Errorf("failed to get vector from index: %w", err)
+			}
+
+			entry.Vectors = make([]Vector, count)
+			for i := range entry.Vectors {
+				entry.Vectors[i] = values[uint(i)*c.Config.Dimensions : uint(i+1)*c.Config.Dimensions]
+			}
+		}
+
+		if options.IncludeMetadata {
+			entry.Metadata = c.metadata.get(key)
+		}
+
+		if options.IncludeText {
+			entry.Text = c.text.get(key)
+		}
+
+		page.Entries = append(page.Entries, entry)
+	}
+
+	return page, nil
+}
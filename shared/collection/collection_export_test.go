@@ -0,0 +1,59 @@
+package shared_collection
+
+import (
+	"testing"
+)
+
+func TestExportVectors(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(config *CollectionConfig)
+		expected  []Vector
+	}{
+		{
+			name:      "f32",
+			configure: nil,
+			expected:  []Vector{{0.123456, -0.654321, 0.5}},
+		},
+		{
+			name: "i8 with rescoring",
+			configure: func(config *CollectionConfig) {
+				config.Quantization = I8
+				config.Rescore = true
+			},
+			expected: []Vector{{0.123456, -0.654321, 0.5}},
+		},
+		{
+			name: "multi f16 with rescoring",
+			configure: func(config *CollectionConfig) {
+				config.Multi = true
+				config.Quantization = F16
+				config.Rescore = true
+			},
+			expected: []Vector{{0.123456, -0.654321, 0.5}, {0.333333, 0.777777, -0.1}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestCollection(t, test.configure)
+			for _, vector := range test.expected {
+				if _, err := c.Add(1, vector, nil, "", WriteModeInsert); err != nil {
+					t.Fatalf("failed to add vector: %v", err)
+				}
+			}
+
+			var entries []ExportEntry
+			for page, err := range c.Export(0, ExportOptions{PageSize: 10, IncludeVectors: true}) {
+				if err != nil {
+					t.Fatalf("failed to export: %v", err)
+				}
+				entries = append(entries, page.Entries...)
+			}
+
+			if len(entries) != 1 || !sameVectors(entries[0].Vectors, test.expected) {
+				t.Errorf("expected the exact vectors %v, got %v", test.expected, entries)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package shared_collection
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// rename is os.Rename, replaced by the tests to simulate a crash between the renames of a save
+var rename = os.Rename
+
+// stagedFile is a file written next to its final path and renamed over it once all the files of the shard have been
+// written and the save has been committed, so a crash while saving never leaves a truncated file behind.
+type stagedFile struct {
+	path     string
+	tmpPath  string
+	checksum string
+}
+
+func tmpFilePath(path string) string {
+	return path + ".tmp"
+}
+
+// stageFile calls write to write the file to a temporary path, flushes it to the disk and computes its checksum
+func stageFile(path string, write func(path string) error) (*stagedFile, error) {
+	f := &stagedFile{
+		path:    path,
+		tmpPath: tmpFilePath(path),
+	}
+
+	err := write(f.tmpPath)
+	if err == nil {
+		f.checksum, err = syncAndChecksumFile(f.tmpPath)
+	}
+
+	if err != nil {
+		f.discard()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// commitFilePath is where the manifest of a committed save waits for the staged files to be renamed, its presence
+// means that the save must be completed
+func commitFilePath(path string) string {
+	return manifestFilePath(path) + ".commit"
+}
+
+// shardFilePaths returns the paths of the files of the shard described by the manifest
+func shardFilePaths(path string) []string {
+	return []string{path, keysFilePath(path), metadataFilePath(path), textFilePath(path), vectorsFilePath(path)}
+}
+
+// RecoverShard brings the shard at path back to a consistent state after a crash while saving it. If the save had
+// been committed the staged files are renamed over the old ones and the new manifest is installed, otherwise the
+// staged files are discarded and the shard is left as it was before the save.
+func RecoverShard(path string) error {
+	_, err := os.Stat(commitFilePath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		for _, filePath := range append(shardFilePaths(path), manifestFilePath(path)) {
+			_ = os.Remove(tmpFilePath(filePath))
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to recover shard %s: %w", path, err)
+	}
+
+	return completeCommit(path)
+}
+
+// completeCommit renames the staged files of the committed save over the old ones and installs the manifest last, the
+// staged files already renamed by an interrupted completion are skipped
+func completeCommit(path string) error {
+	for _, filePath := range shardFilePaths(path) {
+		err := rename(tmpFilePath(filePath), filePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rename %s to %s: %w", tmpFilePath(filePath), filePath, err)
+		}
+	}
+
+	err := rename(commitFilePath(path), manifestFilePath(path))
+	if err != nil {
+		return fmt.Errorf("failed to rename %s to %s: %w", commitFilePath(path), manifestFilePath(path), err)
+	}
+
+	return syncDir(path)
+}
+
+func (f *stagedFile) discard() {
+	_ = os.Remove(f.tmpPath)
+}
+
+func syncAndChecksumFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	err = file.Sync()
+	if err != nil {
+		return "", fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+
+	return checksumReader(file, path)
+}
+
+func checksumFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	return checksumReader(file, path)
+}
+
+func checksumReader(reader io.Reader, path string) (string, error) {
+	hash := sha256.New()
+	_, err := io.Copy(hash, reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// syncDir flushes the directory to the disk to persist the renames of the files
+func syncDir(path string) error {
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("failed to open directory of %s: %w", path, err)
+	}
+	defer dir.Close()
+
+	err = dir.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync directory of %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package shared_collection
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveCrashBetweenRenames(t *testing.T) {
+	tests := []struct {
+		name string
+		// crashAt returns the destination of the rename that fails, simulating a crash of the process
+		crashAt func(path string) string
+		// committed is set if the crash happens once the save has been committed
+		committed bool
+	}{
+		{name: "before the commit", crashAt: commitFilePath, committed: false},
+		{name: "after the index rename", crashAt: keysFilePath, committed: true},
+		{name: "after the sidecar renames", crashAt: vectorsFilePath, committed: true},
+		{name: "before the manifest rename", crashAt: manifestFilePath, committed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "shard")
+			configure := func(config *CollectionConfig) { config.Rescore = true }
+
+			c := newTestCollection(t, configure)
+			if _, err := c.Add(1, Vector{1, 2, 3}, Metadata{"generation": 1.0}, "first", WriteModeInsert); err != nil {
+				t.Fatalf("failed to add key 1: %v", err)
+			}
+			if err := c.Save(path); err != nil {
+				t.Fatalf("failed to save shard: %v", err)
+			}
+
+			if _, err := c.Add(2, Vector{4, 5, 6}, Metadata{"generation": 2.0}, "second", WriteModeInsert); err != nil {
+				t.Fatalf("failed to add key 2: %v", err)
+			}
+
+			crashPath := test.crashAt(path)
+			rename = func(from string, to string) error {
+				if to == crashPath {
+					return errors.New("crash")
+				}
+				return os.Rename(from, to)
+			}
+			err := c.Save(path)
+			rename = os.Rename
+			if err == nil {
+				t.Fatal("expected the save to fail")
+			}
+
+			loaded := newTestCollection(t, configure)
+			if err := loaded.Load(path); err != nil {
+				t.Fatalf("failed to load shard: %v", err)
+			}
+
+			if !loaded.Has(1) || loaded.Has(2) != test.committed {
+				t.Errorf("expected key 1 and, if committed, key 2, got %v and %v", loaded.Has(1), loaded.Has(2))
+			}
+			vectors, metadata, err := loaded.Get(2)
+			if err != nil {
+				t.Fatalf("failed to get key 2: %v", err)
+			}
+			if test.committed && (len(vectors) != 1 || metadata["generation"] != 2.0) {
+				t.Errorf("expected key 2 with its metadata, got %v and %v", vectors, metadata)
+			}
+
+			for _, filePath := range append(shardFilePaths(path), manifestFilePath(path)) {
+				if _, err := os.Stat(tmpFilePath(filePath)); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("expected %s to be removed", tmpFilePath(filePath))
+				}
+			}
+			if _, err := os.Stat(commitFilePath(path)); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("expected %s to be removed", commitFilePath(path))
+			}
+
+			// The collection that failed to save can save again
+			if err := c.Save(path); err != nil {
+				t.Fatalf("failed to save shard again: %v", err)
+			}
+			manifest, err := LoadManifest(path)
+			if err != nil {
+				t.Fatalf("failed to load manifest: %v", err)
+			}
+			if err := manifest.Verify(path); err != nil {
+				t.Errorf("expected the shard to match its manifest, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRecoverShardWithoutShard(t *testing.T) {
+	if err := RecoverShard(filepath.Join(t.TempDir(), "shard")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,435 @@
+package shared_collection
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FilterMaxDepth bounds the nesting of the parentheses and of the NOT of a filter expression, the parser and the
+// evaluation of the expression are recursive
+const FilterMaxDepth = 64
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Filter is a parsed metadata filter expression, e.g. `lang == "en" AND (year >= 2020 OR NOT draft)`.
+// A comparison is made of a field, an operator (==, !=, <, <=, >, >=) and a string, number or bool literal, a bare
+// field is a shorthand for `field == true`. Comparisons can be combined with AND, OR, NOT and parentheses.
+// A comparison on a field missing from the metadata of a key, or holding a value of a different type, is false.
+type Filter struct {
+	expression string
+	root       filterNode
+}
+
+type filterNode interface {
+	eval(metadata Metadata) bool
+	validate(store *metadataStore) error
+}
+
+type filterAnd struct{ left, right filterNode }
+type filterOr struct{ left, right filterNode }
+type filterNot struct{ node filterNode }
+
+type filterComparison struct {
+	field string
+	op    string
+	value any
+}
+
+// ParseFilter parses the expression, only the syntax is checked, the fields are checked against the metadata of the
+// shard when searching.
+func ParseFilter(expression string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFilter, err)
+	}
+
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %s at position %d", p.tokens[p.pos].text, p.tokens[p.pos].pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFilter, err)
+	}
+
+	return &Filter{expression: expression, root: root}, nil
+}
+
+func (f *Filter) String() string {
+	return f.expression
+}
+
+func (f *Filter) Accept(metadata Metadata) bool {
+	return f.root.eval(metadata)
+}
+
+// validate checks that the fields exist in the metadata of the shard and that they hold values of the type of the
+// literals they are compared to.
+func (f *Filter) validate(store *metadataStore) error {
+	err := f.root.validate(store)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidFilter, err)
+	}
+
+	return nil
+}
+
+func (n *filterAnd) eval(metadata Metadata) bool {
+	return n.left.eval(metadata) && n.right.eval(metadata)
+}
+
+func (n *filterAnd) validate(store *metadataStore) error {
+	return errors.Join(n.left.validate(store), n.right.validate(store))
+}
+
+func (n *filterOr) eval(metadata Metadata) bool {
+	return n.left.eval(metadata) || n.right.eval(metadata)
+}
+
+func (n *filterOr) validate(store *metadataStore) error {
+	return errors.Join(n.left.validate(store), n.right.validate(store))
+}
+
+func (n *filterNot) eval(metadata Metadata) bool {
+	return !n.node.eval(metadata)
+}
+
+func (n *filterNot) validate(store *metadataStore) error {
+	return n.node.validate(store)
+}
+
+func (n *filterComparison) eval(metadata Metadata) bool {
+	value, found := metadata[n.field]
+	if !found {
+		return false
+	}
+
+	switch literal := n.value.(type) {
+	case string:
+		v, ok := value.(string)
+		return ok && compareFilterValues(strings.Compare(v, literal), n.op)
+	case float64:
+		v, ok := value.(float64)
+		if !ok {
+			return false
+		}
+		c := 0
+		if v < literal {
+			c = -1
+		} else if v > literal {
+			c = 1
+		}
+		return compareFilterValues(c, n.op)
+	case bool:
+		v, ok := value.(bool)
+		if !ok {
+			return false
+		}
+		return (v == literal) == (n.op == "==")
+	}
+
+	return false
+}
+
+func (n *filterComparison) validate(store *metadataStore) error {
+	types := store.fieldTypes(n.field)
+	if types == nil {
+		return fmt.Errorf("unknown field %s", n.field)
+	}
+
+	t, _ := metadataTypeOf(n.value)
+	if _, ok := types[t]; !ok {
+		found := make([]string, 0, len(types))
+		for _, ft := range []metadataType{metadataTypeString, metadataTypeNumber, metadataTypeBool} {
+			if _, ok := types[ft]; ok {
+				found = append(found, ft.String())
+			}
+		}
+
+		return fmt.Errorf("field %s is %s, compared to a %s", n.field, strings.Join(found, " or "), t)
+	}
+
+	return nil
+}
+
+// compareFilterValues applies the operator to the result of the comparison of the value with the literal
+func compareFilterValues(c int, op string) bool {
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+
+	return false
+}
+
+type filterTokenKind uint8
+
+const (
+	filterTokenIdentifier filterTokenKind = iota
+	filterTokenString
+	filterTokenNumber
+	filterTokenOperator
+	filterTokenOpenParen
+	filterTokenCloseParen
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int
+}
+
+func tokenizeFilter(expression string) ([]filterToken, error) {
+	var tokens []filterToken
+
+	for i := 0; i < len(expression); {
+		ch, size := utf8.DecodeRuneInString(expression[i:])
+
+		switch {
+		case unicode.IsSpace(ch):
+			i += size
+		case ch == '(':
+			tokens = append(tokens, filterToken{kind: filterTokenOpenParen, text: "(", pos: i})
+			i++
+		case ch == ')':
+			tokens = append(tokens, filterToken{kind: filterTokenCloseParen, text: ")", pos: i})
+			i++
+		case strings.ContainsRune("=!<>", ch):
+			op := expression[i : i+1]
+			if i+1 < len(expression) && expression[i+1] == '=' {
+				op = expression[i : i+2]
+			}
+			if op == "=" || op == "!" {
+				return nil, fmt.Errorf("invalid operator %s at position %d", op, i)
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenOperator, text: op, pos: i})
+			i += len(op)
+		case ch == '"':
+			end := i + 1
+			for ; end < len(expression) && expression[end] != '"'; end++ {
+				if expression[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expression) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenString, text: expression[i : end+1], pos: i})
+			i = end + 1
+		case ch == '-' || ch == '.' || (ch >= '0' && ch <= '9'):
+			end := i + 1
+			for ; end < len(expression) && strings.ContainsRune("0123456789.eE+-", rune(expression[end])); end++ {
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenNumber, text: expression[i:end], pos: i})
+			i = end
+		case ch == '_' || unicode.IsLetter(ch):
+			end := i + size
+			for end < len(expression) {
+				next, nextSize := utf8.DecodeRuneInString(expression[end:])
+				if next != '_' && next != '.' && !unicode.IsLetter(next) && !unicode.IsDigit(next) {
+					break
+				}
+				end += nextSize
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenIdentifier, text: expression[i:end], pos: i})
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", ch, i)
+		}
+	}
+
+	return tokens, nil
+}
+
+// filterParser is a recursive descent parser, NOT binds tighter than AND which binds tighter than OR, depth counts the
+// parentheses and the NOT being parsed
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+	depth  int
+}
+
+// enter is called before parsing a nested expression, the caller must call leave once it has been parsed
+func (p *filterParser) enter(token *filterToken) error {
+	p.depth++
+	if p.depth > FilterMaxDepth {
+		return fmt.Errorf("expression nested deeper than %d levels at position %d", FilterMaxDepth, token.pos)
+	}
+
+	return nil
+}
+
+func (p *filterParser) leave() {
+	p.depth--
+}
+
+func (p *filterParser) peek() *filterToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+
+	return &p.tokens[p.pos]
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	token := p.peek()
+	return token != nil && token.kind == filterTokenIdentifier && strings.EqualFold(token.text, keyword)
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterOr{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("AND") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterAnd{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (filterNode, error) {
+	if p.peekKeyword("NOT") {
+		if err := p.enter(p.peek()); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+
+		p.pos++
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &filterNot{node: node}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (filterNode, error) {
+	token := p.peek()
+	if token == nil {
+		return nil, errors.New("unexpected end of expression")
+	}
+
+	if token.kind == filterTokenOpenParen {
+		if err := p.enter(token); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		closing := p.peek()
+		if closing == nil || closing.kind != filterTokenCloseParen {
+			return nil, fmt.Errorf("missing closing parenthesis for position %d", token.pos)
+		}
+		p.pos++
+
+		return node, nil
+	}
+
+	if token.kind != filterTokenIdentifier || isFilterReservedWord(token.text) {
+		return nil, fmt.Errorf("expected field at position %d, got %s", token.pos, token.text)
+	}
+	p.pos++
+
+	// A bare field is a shorthand for field == true
+	op := p.peek()
+	if op == nil || op.kind != filterTokenOperator {
+		return &filterComparison{field: token.text, op: "==", value: true}, nil
+	}
+	p.pos++
+
+	value, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, isBool := value.(bool); isBool && op.text != "==" && op.text != "!=" {
+		return nil, fmt.Errorf("operator %s at position %d can't be used with a bool", op.text, op.pos)
+	}
+
+	return &filterComparison{field: token.text, op: op.text, value: value}, nil
+}
+
+func (p *filterParser) parseLiteral() (any, error) {
+	token := p.peek()
+	if token == nil {
+		return nil, errors.New("unexpected end of expression, expected a value")
+	}
+	p.pos++
+
+	switch {
+	case token.kind == filterTokenString:
+		value, err := strconv.Unquote(token.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string at position %d", token.pos)
+		}
+		return value, nil
+	case token.kind == filterTokenNumber:
+		value, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at position %d", token.text, token.pos)
+		}
+		return value, nil
+	case token.kind == filterTokenIdentifier && strings.EqualFold(token.text, "true"):
+		return true, nil
+	case token.kind == filterTokenIdentifier && strings.EqualFold(token.text, "false"):
+		return false, nil
+	}
+
+	return nil, fmt.Errorf("expected a value at position %d, got %s", token.pos, token.text)
+}
+
+func isFilterReservedWord(word string) bool {
+	for _, reserved := range []string{"AND", "OR", "NOT", "true", "false"} {
+		if strings.EqualFold(word, reserved) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,166 @@
+package shared_collection
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// formatFilterNode renders the tree with every AND, OR and NOT parenthesized so the grouping chosen by the parser is
+// visible
+func formatFilterNode(node filterNode) string {
+	switch n := node.(type) {
+	case *filterAnd:
+		return fmt.Sprintf("(%s AND %s)", formatFilterNode(n.left), formatFilterNode(n.right))
+	case *filterOr:
+		return fmt.Sprintf("(%s OR %s)", formatFilterNode(n.left), formatFilterNode(n.right))
+	case *filterNot:
+		return fmt.Sprintf("(NOT %s)", formatFilterNode(n.node))
+	case *filterComparison:
+		return fmt.Sprintf("%s %s %#v", n.field, n.op, n.value)
+	}
+
+	return fmt.Sprintf("%T", node)
+}
+
+func TestParseFilterPrecedence(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{`a`, `a == true`},
+		{`a AND b OR c`, `((a == true AND b == true) OR c == true)`},
+		{`a OR b AND c`, `(a == true OR (b == true AND c == true))`},
+		{`NOT a AND b`, `((NOT a == true) AND b == true)`},
+		{`NOT a OR NOT b`, `((NOT a == true) OR (NOT b == true))`},
+		{`NOT NOT a`, `(NOT (NOT a == true))`},
+		{`a AND b AND c`, `((a == true AND b == true) AND c == true)`},
+		{`a OR b OR c`, `((a == true OR b == true) OR c == true)`},
+		{`(a OR b) AND c`, `((a == true OR b == true) AND c == true)`},
+		{`NOT (a OR b)`, `(NOT (a == true OR b == true))`},
+		{`a and b or not c`, `((a == true AND b == true) OR (NOT c == true))`},
+		{`((a))`, `a == true`},
+		{`lang == "en" AND (year >= 2020 OR NOT draft)`,
+			`(lang == "en" AND (year >= 2020 OR (NOT draft == true)))`},
+		{`year<2020`, `year < 2020`},
+		{`score != -1.5e3`, `score != -1500`},
+		{`draft == FALSE`, `draft == false`},
+		{`title == "say \"hi\""`, `title == "say \"hi\""`},
+		{`città == "Roma"`, `città == "Roma"`},
+		{`_private.field2 <= .5`, `_private.field2 <= 0.5`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			filter, err := ParseFilter(test.expression)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual := formatFilterNode(filter.root); actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{``, "unexpected end of expression"},
+		{`a AND`, "unexpected end of expression"},
+		{`NOT`, "unexpected end of expression"},
+		{`a ==`, "unexpected end of expression, expected a value"},
+		{`a = 1`, "invalid operator = at position 2"},
+		{`a ! 1`, "invalid operator ! at position 2"},
+		{`a == "en`, "unterminated string at position 5"},
+		{`a == 1.2.3`, "invalid number 1.2.3 at position 5"},
+		{`a == b`, "expected a value at position 5, got b"},
+		{`a < true`, "operator < at position 2 can't be used with a bool"},
+		{`(a OR b`, "missing closing parenthesis for position 0"},
+		{`a OR b)`, "unexpected ) at position 6"},
+		{`a b`, "unexpected b at position 2"},
+		{`AND a`, "expected field at position 0, got AND"},
+		{`true`, "expected field at position 0, got true"},
+		{`== 1`, "expected field at position 0, got =="},
+		{`a == 1 # b`, "unexpected character '#' at position 7"},
+		{`a == 1 → b`, "unexpected character '→' at position 7"},
+		{strings.Repeat("(", FilterMaxDepth+1) + "a" + strings.Repeat(")", FilterMaxDepth+1),
+			fmt.Sprintf("expression nested deeper than %d levels at position %d", FilterMaxDepth, FilterMaxDepth)},
+		{strings.Repeat("NOT ", FilterMaxDepth+1) + "a",
+			fmt.Sprintf("expression nested deeper than %d levels at position %d", FilterMaxDepth, FilterMaxDepth*4)},
+	}
+
+	for _, test := range tests {
+		name := test.expression
+		if len(name) > 32 {
+			name = name[:32]
+		}
+
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseFilter(test.expression)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("expected the error to wrap ErrInvalidFilter, got %v", err)
+			}
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected the error to contain %q, got %q", test.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseFilterMaxDepth(t *testing.T) {
+	expression := strings.Repeat("(", FilterMaxDepth) + "a" + strings.Repeat(")", FilterMaxDepth)
+	if _, err := ParseFilter(expression); err != nil {
+		t.Errorf("expected %d levels to be accepted, got %v", FilterMaxDepth, err)
+	}
+
+	expression = strings.Repeat("NOT ", FilterMaxDepth) + "a"
+	if _, err := ParseFilter(expression); err != nil {
+		t.Errorf("expected %d NOT to be accepted, got %v", FilterMaxDepth, err)
+	}
+}
+
+func TestFilterAccept(t *testing.T) {
+	metadata := Metadata{"lang": "en", "year": float64(2021), "draft": false}
+
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{`lang == "en"`, true},
+		{`lang != "en"`, false},
+		{`lang < "fr"`, true},
+		{`year >= 2020 AND year < 2022`, true},
+		{`year > 2021`, false},
+		{`draft`, false},
+		{`NOT draft`, true},
+		{`draft == false`, true},
+		{`missing == 1`, false},
+		{`missing != 1`, false},
+		{`lang == 1`, false},
+		{`year == "2021"`, false},
+		{`lang == "it" OR year == 2021 AND NOT draft`, true},
+		{`(lang == "it" OR year == 2021) AND draft`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			filter, err := ParseFilter(test.expression)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if actual := filter.Accept(metadata); actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package shared_collection
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// FusionStrategy is how the results of the text index and of the vector index are blended by a hybrid search
+type FusionStrategy int
+
+const (
+	// FusionReciprocalRank scores each key with the sum of 1 / (RRFRankConstant + rank) over the two result lists, it
+	// only depends on the ranks so the BM25 scores and the distances don't need to be comparable.
+	FusionReciprocalRank FusionStrategy = iota
+	// FusionWeightedScore normalizes the BM25 scores and the distances of the candidates to [0, 1] and sums them
+	// weighted by TextWeight.
+	FusionWeightedScore
+)
+
+// RRFRankConstant dampens the weight of the top ranks in the reciprocal rank fusion
+const RRFRankConstant = 60
+
+// HybridDefaultTextWeight is the weight of the text score used by FusionWeightedScore if not specified
+const HybridDefaultTextWeight = 0.5
+
+// hybridFetchFactor is how many more candidates than the limit are fetched from each index before fusing them
+const hybridFetchFactor = 4
+
+func (s FusionStrategy) IsValid() bool {
+	return s == FusionReciprocalRank || s == FusionWeightedScore
+}
+
+func (s FusionStrategy) String() string {
+	switch s {
+	case FusionReciprocalRank:
+		return "rrf"
+	case FusionWeightedScore:
+		return "weighted"
+	default:
+		return fmt.Sprintf("unknown(%d)", s)
+	}
+}
+
+type hybridCandidate struct {
+	key        Key
+	distance   float32
+	vectorRank int
+	textRank   int
+	textScore  float32
+	score      float32
+}
+
+// hybridSearch blends the keys closest to the query with the keys whose text best matches options.TextQuery, the
+// filters apply to both. The distance of the keys found only by the text index is computed, so every key returned has
+// its distance and the keys farther than MaxDistance are dropped; the keys are ordered by fused score, higher first.
+// The caller must hold the read lock.
+func (c *Collection) hybridSearch(
+	query Vector,
+	limit uint32,
+	options *SearchOptions) ([]Key, []float32, []float32, error) {
+	if c.Config.Multi && c.keys == nil {
+		return nil, nil, nil, ErrKeysNotTracked
+	}
+
+	fetch, err := c.clampLimit(uint64(limit) * hybridFetchFactor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	maxDistance := options.maxDistance()
+
+	vectorKeys, distances, err := c.rescoredSearchKeys(query, fetch, options)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	terms := c.text.queryTerms(options.TextQuery)
+	textKeys, textScores := c.text.search(terms, fetch, c.accept(options))
+
+	candidates := make([]*hybridCandidate, 0, len(vectorKeys)+len(textKeys))
+	byKey := make(map[Key]*hybridCandidate, len(vectorKeys)+len(textKeys))
+	for i, key := range vectorKeys {
+		candidate := &hybridCandidate{key: key, distance: distances[i], vectorRank: i + 1}
+		candidates = append(candidates, candidate)
+		byKey[key] = candidate
+	}
+
+	for i, key := range textKeys {
+		candidate, found := byKey[key]
+		if !found {
+			distance, stored, err := c.keyDistance(query, key)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			if !stored || distance > maxDistance {
+				continue
+			}
+
+			candidate = &hybridCandidate{key: key, distance: distance}
+			candidates = append(candidates, candidate)
+			byKey[key] = candidate
+		}
+
+		candidate.textRank = i + 1
+		candidate.textScore = textScores[i]
+	}
+
+	switch options.Fusion {
+	case FusionReciprocalRank:
+		fuseReciprocalRank(candidates)
+	case FusionWeightedScore:
+		// The keys found only by the vector index can still match the text, they are scored as well
+		for _, candidate := range candidates {
+			if candidate.textRank == 0 {
+				candidate.textScore = c.text.score(terms, candidate.key)
+			}
+		}
+
+		fuseWeightedScore(candidates, options.TextWeight)
+	default:
+		return nil, nil, nil, fmt.Errorf("invalid fusion strategy: %s", options.Fusion)
+	}
+
+	slices.SortStableFunc(candidates, func(a, b *hybridCandidate) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.distance, b.distance)
+	})
+	candidates = candidates[:min(len(candidates), int(limit))]
+
+	keys := make([]Key, len(candidates))
+	resultDistances := make([]float32, len(candidates))
+	scores := make([]float32, len(candidates))
+	for i, candidate := range candidates {
+		keys[i] = candidate.key
+		resultDistances[i] = candidate.distance
+		scores[i] = candidate.score
+	}
+
+	return keys, resultDistances, scores, nil
+}
+
+func fuseReciprocalRank(candidates []*hybridCandidate) {
+	for _, candidate := range candidates {
+		candidate.score = 0
+		if candidate.vectorRank > 0 {
+			candidate.score += 1 / float32(RRFRankConstant+candidate.vectorRank)
+		}
+		if candidate.textRank > 0 {
+			candidate.score += 1 / float32(RRFRankConstant+candidate.textRank)
+		}
+	}
+}
+
+// fuseWeightedScore normalizes the distances, closest to 1 and farthest to 0, and the BM25 scores, dividing them by the
+// highest one, and blends them with textWeight
+func fuseWeightedScore(candidates []*hybridCandidate, textWeight float32) {
+	if len(candidates) == 0 {
+		return
+	}
+
+	minDistance, maxDistance := candidates[0].distance, candidates[0].distance
+	maxTextScore := float32(0)
+	for _, candidate := range candidates {
+		minDistance = min(minDistance, candidate.distance)
+		maxDistance = max(maxDistance, candidate.distance)
+		maxTextScore = max(maxTextScore, candidate.textScore)
+	}
+
+	for _, candidate := range candidates {
+		vectorScore := float32(1)
+		if maxDistance > minDistance {
+			vectorScore = 1 - (candidate.distance-minDistance)/(maxDistance-minDistance)
+		}
+
+		textScore := float32(0)
+		if maxTextScore > 0 {
+			textScore = candidate.textScore / maxTextScore
+		}
+
+		candidate.score = (1-textWeight)*vectorScore + textWeight*textScore
+	}
+}
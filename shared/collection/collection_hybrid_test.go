@@ -0,0 +1,274 @@
+package shared_collection
+
+import (
+	"math"
+	"testing"
+)
+
+func assertHybridScores(t *testing.T, candidates []*hybridCandidate, expected []float32) {
+	t.Helper()
+
+	for i, candidate := range candidates {
+		if math.Abs(float64(candidate.score-expected[i])) > 1e-6 {
+			t.Errorf("candidate %d: expected score %g, got %g", i, expected[i], candidate.score)
+		}
+	}
+}
+
+func TestFuseReciprocalRank(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []*hybridCandidate
+		expected   []float32
+	}{
+		{
+			name:       "no candidates",
+			candidates: nil,
+			expected:   nil,
+		},
+		{
+			name: "found by both indexes",
+			candidates: []*hybridCandidate{
+				{key: 1, vectorRank: 1, textRank: 1},
+				{key: 2, vectorRank: 2, textRank: 3},
+			},
+			expected: []float32{2.0 / 61, 1.0/62 + 1.0/63},
+		},
+		{
+			name: "found by one index",
+			candidates: []*hybridCandidate{
+				{key: 1, vectorRank: 1},
+				{key: 2, textRank: 1},
+				{key: 3, vectorRank: 5},
+			},
+			expected: []float32{1.0 / 61, 1.0 / 61, 1.0 / 65},
+		},
+		{
+			name: "ranks only, distances and text scores ignored",
+			candidates: []*hybridCandidate{
+				{key: 1, vectorRank: 2, textRank: 2, distance: 100, textScore: 0.1},
+				{key: 2, vectorRank: 1, textRank: 3, distance: 0, textScore: 50},
+			},
+			expected: []float32{2.0 / 62, 1.0/61 + 1.0/63},
+		},
+		{
+			name:       "previous score reset",
+			candidates: []*hybridCandidate{{key: 1, vectorRank: 1, score: 10}},
+			expected:   []float32{1.0 / 61},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fuseReciprocalRank(test.candidates)
+			assertHybridScores(t, test.candidates, test.expected)
+		})
+	}
+}
+
+func TestFuseWeightedScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []*hybridCandidate
+		textWeight float32
+		expected   []float32
+	}{
+		{
+			name:       "no candidates",
+			candidates: nil,
+			textWeight: 0.5,
+			expected:   nil,
+		},
+		{
+			name: "balanced",
+			candidates: []*hybridCandidate{
+				{key: 1, distance: 0, textScore: 2},
+				{key: 2, distance: 1, textScore: 4},
+				{key: 3, distance: 2, textScore: 0},
+			},
+			textWeight: 0.5,
+			expected:   []float32{0.75, 0.75, 0},
+		},
+		{
+			name: "vector only",
+			candidates: []*hybridCandidate{
+				{key: 1, distance: 1, textScore: 4},
+				{key: 2, distance: 3, textScore: 1},
+			},
+			textWeight: 0,
+			expected:   []float32{1, 0},
+		},
+		{
+			name: "text only",
+			candidates: []*hybridCandidate{
+				{key: 1, distance: 1, textScore: 1},
+				{key: 2, distance: 3, textScore: 4},
+			},
+			textWeight: 1,
+			expected:   []float32{0.25, 1},
+		},
+		{
+			name: "same distance",
+			candidates: []*hybridCandidate{
+				{key: 1, distance: 2, textScore: 1},
+				{key: 2, distance: 2, textScore: 2},
+			},
+			textWeight: 0.5,
+			expected:   []float32{0.75, 1},
+		},
+		{
+			name: "no text match",
+			candidates: []*hybridCandidate{
+				{key: 1, distance: 0},
+				{key: 2, distance: 4},
+			},
+			textWeight: 0.25,
+			expected:   []float32{0.75, 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fuseWeightedScore(test.candidates, test.textWeight)
+			assertHybridScores(t, test.candidates, test.expected)
+		})
+	}
+}
+
+func newHybridTestCollection(t *testing.T, count int) *Collection {
+	t.Helper()
+
+	config := NewCollectionConfig()
+	config.Dimensions = 2
+	config.MaxSize = 1 << 20
+	config.Metric = L2sq
+	config.VectorValidation = DefaultVectorValidation(L2sq)
+	config.Rescore = true
+	config.TempDir = t.TempDir()
+
+	c, err := NewCollection(config)
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Destroy() })
+
+	texts := []string{"red car", "blue car", "red bike", "green boat", "red boat"}
+	for i := 0; i < count; i++ {
+		_, err := c.Add(Key(i+1), Vector{float32(i), 0}, nil, texts[i%len(texts)], WriteModeInsert)
+		if err != nil {
+			t.Fatalf("failed to add key %d: %v", i+1, err)
+		}
+	}
+
+	return c
+}
+
+func TestClampLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		length   int
+		limit    uint64
+		expected uint32
+	}{
+		{"empty index", 0, 10, 1},
+		{"zero limit", 5, 0, 1},
+		{"within the length", 5, 3, 3},
+		{"length", 5, 5, 5},
+		{"above the length", 5, 100, 5},
+		{"above 32 bits", 5, math.MaxUint32 * 4, 5},
+		{"max", 5, math.MaxUint64, 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newHybridTestCollection(t, test.length)
+
+			limit, err := c.clampLimit(test.limit)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if limit != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, limit)
+			}
+		})
+	}
+}
+
+func TestHybridSearch(t *testing.T) {
+	tests := []struct {
+		name     string
+		length   int
+		limit    uint32
+		options  SearchOptions
+		expected []Key
+	}{
+		{
+			name:     "rrf",
+			length:   5,
+			limit:    3,
+			options:  SearchOptions{TextQuery: "red", Fusion: FusionReciprocalRank},
+			expected: []Key{1, 3, 5},
+		},
+		{
+			name:     "text match far from the query",
+			length:   5,
+			limit:    1,
+			options:  SearchOptions{TextQuery: "boat", Fusion: FusionWeightedScore, TextWeight: 1},
+			expected: []Key{4},
+		},
+		{
+			name:     "vector only weight",
+			length:   5,
+			limit:    2,
+			options:  SearchOptions{TextQuery: "boat", Fusion: FusionWeightedScore, TextWeight: 0},
+			expected: []Key{1, 2},
+		},
+		{
+			name:     "limit above the length",
+			length:   5,
+			limit:    math.MaxUint32,
+			options:  SearchOptions{TextQuery: "red", Fusion: FusionReciprocalRank},
+			expected: []Key{1, 3, 5, 2, 4},
+		},
+		{
+			name:     "limit above the length with rescoring",
+			length:   5,
+			limit:    1 << 31,
+			options:  SearchOptions{TextQuery: "red", Fusion: FusionWeightedScore, TextWeight: 0.5, RescoreFactor: 8},
+			expected: []Key{1, 3, 5, 2, 4},
+		},
+		{
+			name:     "empty collection",
+			length:   0,
+			limit:    10,
+			options:  SearchOptions{TextQuery: "red", Fusion: FusionReciprocalRank},
+			expected: []Key{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newHybridTestCollection(t, test.length)
+
+			result, err := c.Search(Vector{0, 0}, test.limit, &test.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(result.Keys) != len(test.expected) {
+				t.Fatalf("expected keys %v, got %v", test.expected, result.Keys)
+			}
+			for i := range test.expected {
+				if result.Keys[i] != test.expected[i] {
+					t.Fatalf("expected keys %v, got %v", test.expected, result.Keys)
+				}
+			}
+
+			for i := 1; i < len(result.Scores); i++ {
+				if result.Scores[i] > result.Scores[i-1] {
+					t.Errorf("expected the scores in descending order, got %v", result.Scores)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package shared_collection
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const keysFileMagic = "SVDBKEYS"
+const keysFileVersion = uint32(1)
+
+var ErrKeysNotTracked = errors.New("the keys of the shard are not tracked, the keys file is missing")
+
+// keysRegistry tracks the keys stored in the index and the number of vectors stored under each key, the USearch Go
+// bindings don't provide a way to enumerate the keys or to count the vectors of a key.
+// A nil registry means that the keys are not tracked, it happens when a shard is loaded without its keys file.
+type keysRegistry map[Key]uint32
+
+func keysFilePath(path string) string {
+	return path + ".keys"
+}
+
+func (r keysRegistry) add(key Key) {
+	if r == nil {
+		return
+	}
+
+	r[key]++
+}
+
+func (r keysRegistry) remove(key Key) {
+	if r == nil {
+		return
+	}
+
+	delete(r, key)
+}
+
+func (r keysRegistry) count(key Key) uint32 {
+	return r[key]
+}
+
+func (r keysRegistry) save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create keys file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	buf := make([]byte, 12)
+
+	_, _ = writer.WriteString(keysFileMagic)
+	binary.LittleEndian.PutUint32(buf[0:4], keysFileVersion)
+	binary.LittleEndian.PutUint64(buf[4:12], uint64(len(r)))
+	_, _ = writer.Write(buf)
+
+	for key, count := range r {
+		binary.LittleEndian.PutUint64(buf[0:8], uint64(key))
+		binary.LittleEndian.PutUint32(buf[8:12], count)
+		_, _ = writer.Write(buf)
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write keys file: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close keys file: %w", err)
+	}
+
+	return nil
+}
+
+func loadKeysRegistry(path string) (keysRegistry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keys file: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(keysFileMagic))
+	buf := make([]byte, 12)
+
+	if _, err = io.ReadFull(reader, magic); err != nil || string(magic) != keysFileMagic {
+		return nil, fmt.Errorf("invalid keys file: %s", path)
+	}
+
+	if _, err = io.ReadFull(reader, buf); err != nil {
+		return nil, fmt.Errorf("failed to read keys file header: %w", err)
+	}
+
+	if version := binary.LittleEndian.Uint32(buf[0:4]); version != keysFileVersion {
+		return nil, fmt.Errorf("unsupported keys file version: %d", version)
+	}
+
+	length := binary.LittleEndian.Uint64(buf[4:12])
+	registry := make(keysRegistry, length)
+	for i := uint64(0); i < length; i++ {
+		if _, err = io.ReadFull(reader, buf); err != nil {
+			return nil, fmt.Errorf("failed to read keys file: %w", err)
+		}
+
+		registry[Key(binary.LittleEndian.Uint64(buf[0:8]))] = binary.LittleEndian.Uint32(buf[8:12])
+	}
+
+	return registry, nil
+}
@@ -0,0 +1,172 @@
+package shared_collection
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrManifestMismatch = errors.New("the collection config doesn't match the shard manifest")
+var ErrShardCorrupted = errors.New("the shard is corrupted")
+
+// Manifest describes how the shard has been created, it's saved next to the shard and checked when the shard is loaded
+// so a misconfigured worker doesn't interpret the vectors with the wrong dimensions, metric or quantization.
+// FreeSlots is the number of slots of removed vectors not reused yet, the space Compact would reclaim.
+type Manifest struct {
+	Dimensions     uint      `json:"dimensions"`
+	Metric         string    `json:"metric"`
+	Quantization   string    `json:"quantization"`
+	Connectivity   uint      `json:"connectivity"`
+	Multi          bool      `json:"multi"`
+	MaxSize        uint      `json:"maxSize"`
+	Rescore        bool      `json:"rescore"`
+	UsearchVersion string    `json:"usearchVersion"`
+	CreatedAt      time.Time `json:"createdAt"`
+	SavedAt        time.Time `json:"savedAt"`
+	FreeSlots      uint64    `json:"freeSlots"`
+	Checksums      Checksums `json:"checksums"`
+}
+
+// Checksums are the SHA-256 of the files of the shard, Keys is empty if the keys are not tracked, Text is empty for
+// the shards saved before the text index was introduced and Vectors is empty if the shard isn't rescored
+type Checksums struct {
+	Index    string `json:"index"`
+	Keys     string `json:"keys,omitempty"`
+	Metadata string `json:"metadata"`
+	Text     string `json:"text,omitempty"`
+	Vectors  string `json:"vectors,omitempty"`
+}
+
+func manifestFilePath(path string) string {
+	return path + ".manifest"
+}
+
+// LoadManifest reads the manifest of the shard at path, the error wraps os.ErrNotExist if the shard has no manifest
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(manifestFilePath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest file: %w", err)
+	}
+
+	manifest := &Manifest{}
+	err = json.Unmarshal(data, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest file: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// save writes the manifest to the file at path
+func (m *Manifest) save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write manifest file: %w", err)
+	}
+
+	return nil
+}
+
+// Validate returns an error wrapping ErrManifestMismatch listing the settings of the config that don't match the
+// manifest, a connectivity of 0 in the config means the default one and isn't checked.
+func (m *Manifest) Validate(config *CollectionConfig) error {
+	var mismatches []string
+
+	if config.Dimensions != m.Dimensions {
+		mismatches = append(mismatches, fmt.Sprintf("dimensions %d, shard has %d", config.Dimensions, m.Dimensions))
+	}
+
+	if config.Metric.String() != m.Metric {
+		mismatches = append(mismatches, fmt.Sprintf("metric %s, shard has %s", config.Metric, m.Metric))
+	}
+
+	if config.Quantization.String() != m.Quantization {
+		mismatches = append(
+			mismatches,
+			fmt.Sprintf("quantization %s, shard has %s", config.Quantization, m.Quantization))
+	}
+
+	if config.Connectivity != 0 && config.Connectivity != m.Connectivity {
+		mismatches = append(
+			mismatches,
+			fmt.Sprintf("connectivity %d, shard has %d", config.Connectivity, m.Connectivity))
+	}
+
+	if config.Multi != m.Multi {
+		mismatches = append(mismatches, fmt.Sprintf("multi %t, shard has %t", config.Multi, m.Multi))
+	}
+
+	if config.Rescore != m.Rescore {
+		mismatches = append(mismatches, fmt.Sprintf("rescore %t, shard has %t", config.Rescore, m.Rescore))
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%w: %s", ErrManifestMismatch, strings.Join(mismatches, ", "))
+	}
+
+	return nil
+}
+
+// Verify returns an error wrapping ErrShardCorrupted if the checksums of the files of the shard at path don't match
+// the ones in the manifest
+func (m *Manifest) Verify(path string) error {
+	files := []struct {
+		path     string
+		checksum string
+	}{
+		{path: path, checksum: m.Checksums.Index},
+		{path: keysFilePath(path), checksum: m.Checksums.Keys},
+		{path: metadataFilePath(path), checksum: m.Checksums.Metadata},
+		{path: textFilePath(path), checksum: m.Checksums.Text},
+		{path: vectorsFilePath(path), checksum: m.Checksums.Vectors},
+	}
+
+	for _, file := range files {
+		if file.checksum == "" {
+			continue
+		}
+
+		checksum, err := checksumFile(file.path)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s is missing", ErrShardCorrupted, file.path)
+		} else if err != nil {
+			return err
+		}
+
+		if checksum != file.checksum {
+			return fmt.Errorf("%w: the checksum of %s doesn't match the manifest", ErrShardCorrupted, file.path)
+		}
+	}
+
+	return nil
+}
+
+// manifest returns the manifest of the collection, the caller must hold the lock
+func (c *Collection) manifest() (*Manifest, error) {
+	connectivity, err := c.index.Connectivity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connectivity of index: %w", err)
+	}
+
+	return &Manifest{
+		Dimensions:     c.Config.Dimensions,
+		Metric:         c.Config.Metric.String(),
+		Quantization:   c.Config.Quantization.String(),
+		Connectivity:   connectivity,
+		Multi:          c.Config.Multi,
+		MaxSize:        c.Config.MaxSize,
+		Rescore:        c.Config.Rescore,
+		UsearchVersion: usearchVersion(),
+		CreatedAt:      c.createdAt,
+		SavedAt:        time.Now().UTC(),
+		FreeSlots:      c.freeSlots.Load(),
+	}, nil
+}
@@ -0,0 +1,183 @@
+package shared_collection
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Metadata is the document stored alongside the vectors of a key, the values can only be strings, finite numbers
+// (float64) or bools.
+type Metadata map[string]any
+
+// metadataStore maps the keys to their metadata, it's persisted in a JSON lines sidecar file next to the shard.
+// fields counts, per field and per type, the values stored to validate the filter expressions.
+type metadataStore struct {
+	entries map[Key]Metadata
+	fields  map[string]map[metadataType]uint64
+}
+
+// metadataType is the type of a metadata value
+type metadataType uint8
+
+const (
+	metadataTypeString metadataType = iota
+	metadataTypeNumber
+	metadataTypeBool
+)
+
+type metadataFileEntry struct {
+	Key      Key      `json:"key"`
+	Metadata Metadata `json:"metadata"`
+}
+
+func metadataFilePath(path string) string {
+	return path + ".meta"
+}
+
+func (t metadataType) String() string {
+	switch t {
+	case metadataTypeString:
+		return "string"
+	case metadataTypeNumber:
+		return "number"
+	case metadataTypeBool:
+		return "bool"
+	default:
+		return "unknown"
+	}
+}
+
+// metadataTypeOf returns the type of the value, false if the type is not supported
+func metadataTypeOf(value any) (metadataType, bool) {
+	switch value.(type) {
+	case string:
+		return metadataTypeString, true
+	case float64:
+		return metadataTypeNumber, true
+	case bool:
+		return metadataTypeBool, true
+	default:
+		return 0, false
+	}
+}
+
+func (m Metadata) Validate() error {
+	for field, value := range m {
+		if _, ok := metadataTypeOf(value); !ok {
+			return fmt.Errorf("metadata field %s has unsupported type %T", field, value)
+		}
+
+		// NaN and infinite values can't be encoded in the JSON sidecar file
+		if number, ok := value.(float64); ok && (math.IsNaN(number) || math.IsInf(number, 0)) {
+			return fmt.Errorf("metadata field %s has non-finite value %g", field, number)
+		}
+	}
+
+	return nil
+}
+
+func newMetadataStore() *metadataStore {
+	return &metadataStore{
+		entries: make(map[Key]Metadata),
+		fields:  make(map[string]map[metadataType]uint64),
+	}
+}
+
+func (s *metadataStore) get(key Key) Metadata {
+	return s.entries[key]
+}
+
+func (s *metadataStore) set(key Key, metadata Metadata) {
+	s.remove(key)
+	if len(metadata) == 0 {
+		return
+	}
+
+	s.entries[key] = metadata
+	for field, value := range metadata {
+		t, _ := metadataTypeOf(value)
+		if s.fields[field] == nil {
+			s.fields[field] = make(map[metadataType]uint64)
+		}
+		s.fields[field][t]++
+	}
+}
+
+func (s *metadataStore) remove(key Key) {
+	for field, value := range s.entries[key] {
+		t, _ := metadataTypeOf(value)
+		s.fields[field][t]--
+		if s.fields[field][t] == 0 {
+			delete(s.fields[field], t)
+		}
+		if len(s.fields[field]) == 0 {
+			delete(s.fields, field)
+		}
+	}
+
+	delete(s.entries, key)
+}
+
+// fieldTypes returns the types of the values stored in the field, nil if the field is unknown
+func (s *metadataStore) fieldTypes(field string) map[metadataType]uint64 {
+	return s.fields[field]
+}
+
+func (s *metadataStore) save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create metadata file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for key, metadata := range s.entries {
+		err = encoder.Encode(metadataFileEntry{Key: key, Metadata: metadata})
+		if err != nil {
+			return fmt.Errorf("failed to write metadata file: %w", err)
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close metadata file: %w", err)
+	}
+
+	return nil
+}
+
+func loadMetadataStore(path string) (*metadataStore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata file: %w", err)
+	}
+	defer file.Close()
+
+	store := newMetadataStore()
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for decoder.More() {
+		entry := metadataFileEntry{}
+		err = decoder.Decode(&entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata file: %w", err)
+		}
+
+		err = entry.Metadata.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata for key %d: %w", entry.Key, err)
+		}
+
+		store.set(entry.Key, entry.Metadata)
+	}
+
+	return store, nil
+}
@@ -0,0 +1,152 @@
+package shared_collection
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
+)
+
+// Normalization policies of the vectors written to, and searched in, the Cosine and InnerProduct collections.
+const (
+	// NormalizationNone accepts the vectors as they are.
+	NormalizationNone NormalizationPolicy = iota
+	// NormalizationNormalize scales the vectors not normalized to unit length.
+	NormalizationNormalize
+	// NormalizationReject refuses the vectors not normalized.
+	NormalizationReject
+)
+
+// NormalizationTolerance is the max difference between the L2 norm of a normalized vector and 1
+const NormalizationTolerance = 1e-3
+
+var ErrNotNormalized = errors.New("the vector is not normalized")
+
+type NormalizationPolicy int
+
+func ParseNormalizationPolicy(policy string) (NormalizationPolicy, error) {
+	switch strings.ToLower(policy) {
+	case "none":
+		return NormalizationNone, nil
+	case "normalize":
+		return NormalizationNormalize, nil
+	case "reject-unnormalized":
+		return NormalizationReject, nil
+	default:
+		return 0, fmt.Errorf("invalid normalization policy: %s", policy)
+	}
+}
+
+// String returns the name of the policy as accepted by ParseNormalizationPolicy
+func (p NormalizationPolicy) String() string {
+	switch p {
+	case NormalizationNone:
+		return "none"
+	case NormalizationNormalize:
+		return "normalize"
+	case NormalizationReject:
+		return "reject-unnormalized"
+	default:
+		return fmt.Sprintf("unknown(%d)", p)
+	}
+}
+
+// SupportsNormalization reports if normalizing the vectors is meaningful for the metric
+func (m Metric) SupportsNormalization() bool {
+	return m == Cosine || m == InnerProduct
+}
+
+// Norm returns the L2 norm of the vector
+func (v Vector) Norm() float64 {
+	sum := 0.0
+	for _, value := range v {
+		sum += float64(value) * float64(value)
+	}
+
+	return math.Sqrt(sum)
+}
+
+// NormalizationStats counts the vectors checked by a Normalizer, the ones not normalized, the ones scaled to unit
+// length and the ones refused.
+type NormalizationStats struct {
+	Policy     NormalizationPolicy
+	Vectors    uint64
+	Violations uint64
+	Normalized uint64
+	Rejected   uint64
+}
+
+// Normalizer enforces a normalization policy and counts the violations, it's safe for concurrent use
+type Normalizer struct {
+	policy     NormalizationPolicy
+	vectors    atomic.Uint64
+	violations atomic.Uint64
+	normalized atomic.Uint64
+	rejected   atomic.Uint64
+}
+
+func NewNormalizer(policy NormalizationPolicy) *Normalizer {
+	return &Normalizer{policy: policy}
+}
+
+// Apply enforces the policy on the vectors, kind names them in the errors (e.g. vector or query).
+// With NormalizationNormalize the vectors not normalized are scaled in place, with NormalizationReject, or if a vector
+// with a norm of 0 can't be normalized, none of the vectors is changed and the error wraps ErrNotNormalized and names
+// the first vector refused.
+func (n *Normalizer) Apply(kind string, vectors []Vector) error {
+	if n.policy == NormalizationNone {
+		return nil
+	}
+
+	var err error
+	var rejected uint64
+	norms := make([]float64, len(vectors))
+	for i, vector := range vectors {
+		norms[i] = vector.Norm()
+		if math.Abs(norms[i]-1) <= NormalizationTolerance {
+			continue
+		}
+
+		n.violations.Add(1)
+		if n.policy == NormalizationReject || norms[i] == 0 {
+			rejected++
+			if err == nil && norms[i] == 0 {
+				err = fmt.Errorf("%w: %s %d has norm 0 and can't be normalized", ErrNotNormalized, kind, i)
+			} else if err == nil {
+				err = fmt.Errorf("%w: %s %d has norm %g", ErrNotNormalized, kind, i, norms[i])
+			}
+		}
+	}
+
+	n.vectors.Add(uint64(len(vectors)))
+
+	// The request is refused as a whole, the vectors that could have been normalized are not counted as normalized
+	if err != nil {
+		n.rejected.Add(rejected)
+		return err
+	}
+
+	for i, vector := range vectors {
+		if math.Abs(norms[i]-1) <= NormalizationTolerance {
+			continue
+		}
+
+		for j := range vector {
+			vector[j] = float32(float64(vector[j]) / norms[i])
+		}
+		n.normalized.Add(1)
+	}
+
+	return nil
+}
+
+func (n *Normalizer) Stats() NormalizationStats {
+	return NormalizationStats{
+		Policy:     n.policy,
+		Vectors:    n.vectors.Load(),
+		Violations: n.violations.Load(),
+		Normalized: n.normalized.Load(),
+		Rejected:   n.rejected.Load(),
+	}
+}
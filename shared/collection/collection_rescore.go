@@ -0,0 +1,376 @@
+package shared_collection
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	usearch "github.com/unum-cloud/usearch/golang"
+	"io"
+	"math"
+	"os"
+	"slices"
+)
+
+const vectorsFileMagic = "SVDBF32V"
+const vectorsFileVersion = uint32(1)
+const vectorsFileHeaderLength = int64(len(vectorsFileMagic) + 12)
+
+// RescoreMaxFactor is the max over-fetch factor of a rescored search
+const RescoreMaxFactor = 100
+
+var ErrRescoreDisabled = errors.New("the full-precision vectors are not stored, rescoring is disabled for the collection")
+
+// vectorStore keeps a full-precision copy of the vectors of a quantized index, used to compute the exact distances.
+// Only the location of the vectors is kept in memory, the vectors loaded with the shard are read from its sidecar file
+// and the ones added since from a spill file, a temporary file created in dir and unlinked right away. Save streams the
+// vectors to a new sidecar file, Compact copies them to a new spill file releasing the space of the removed ones.
+// The files are read with ReadAt, the reads are safe for concurrent use while the writes need the write lock.
+type vectorStore struct {
+	dimensions uint
+	dir        string
+	base       *os.File
+	spill      *os.File
+	spillSize  int64
+	locations  map[Key][]vectorLocation
+}
+
+// vectorLocation is the offset of a vector in the sidecar file of the shard or in the spill file
+type vectorLocation struct {
+	spill  bool
+	offset int64
+}
+
+func vectorsFilePath(path string) string {
+	return path + ".f32"
+}
+
+func newVectorStore(dimensions uint, dir string) *vectorStore {
+	return &vectorStore{
+		dimensions: dimensions,
+		dir:        dir,
+		locations:  make(map[Key][]vectorLocation),
+	}
+}
+
+func (s *vectorStore) vectorBytes() int64 {
+	return int64(s.dimensions) * 4
+}
+
+// write appends the vector to the spill file, creating it if needed, and returns its location, the vector is stored
+// under a key by add
+func (s *vectorStore) write(vector Vector) (vectorLocation, error) {
+	if s == nil {
+		return vectorLocation{}, nil
+	}
+
+	if s.spill == nil {
+		spill, err := os.CreateTemp(s.dir, "svdb-vectors-*.f32")
+		if err != nil {
+			return vectorLocation{}, fmt.Errorf("failed to create vectors spill file: %w", err)
+		}
+
+		// The space is released when the file is closed, or by the OS if the process crashes
+		_ = os.Remove(spill.Name())
+		s.spill = spill
+	}
+
+	buf := make([]byte, s.vectorBytes())
+	for i, value := range vector {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(value))
+	}
+
+	_, err := s.spill.WriteAt(buf, s.spillSize)
+	if err != nil {
+		return vectorLocation{}, fmt.Errorf("failed to write vectors spill file: %w", err)
+	}
+
+	location := vectorLocation{spill: true, offset: s.spillSize}
+	s.spillSize += int64(len(buf))
+
+	return location, nil
+}
+
+func (s *vectorStore) add(key Key, location vectorLocation) {
+	if s == nil {
+		return
+	}
+
+	s.locations[key] = append(s.locations[key], location)
+}
+
+func (s *vectorStore) remove(key Key) {
+	if s == nil {
+		return
+	}
+
+	delete(s.locations, key)
+}
+
+// readRaw reads the little endian values of the vector at location into buf
+func (s *vectorStore) readRaw(location vectorLocation, buf []byte) error {
+	file := s.base
+	if location.spill {
+		file = s.spill
+	}
+
+	_, err := file.ReadAt(buf, location.offset)
+	if err != nil {
+		return fmt.Errorf("failed to read full-precision vector: %w", err)
+	}
+
+	return nil
+}
+
+func (s *vectorStore) get(key Key) ([]Vector, error) {
+	locations := s.locations[key]
+	if len(locations) == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, s.vectorBytes())
+	vectors := make([]Vector, len(locations))
+	for i, location := range locations {
+		err := s.readRaw(location, buf)
+		if err != nil {
+			return nil, err
+		}
+
+		vectors[i] = make(Vector, s.dimensions)
+		for j := range vectors[i] {
+			vectors[i][j] = math.Float32frombits(binary.LittleEndian.Uint32(buf[j*4:]))
+		}
+	}
+
+	return vectors, nil
+}
+
+func (s *vectorStore) save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create vectors file: %w", err)
+	}
+	defer file.Close()
+
+	count := uint64(0)
+	for _, locations := range s.locations {
+		count += uint64(len(locations))
+	}
+
+	writer := bufio.NewWriter(file)
+	buf := make([]byte, 12)
+
+	_, _ = writer.WriteString(vectorsFileMagic)
+	binary.LittleEndian.PutUint32(buf[0:4], vectorsFileVersion)
+	binary.LittleEndian.PutUint64(buf[4:12], count)
+	_, _ = writer.Write(buf)
+
+	vector := make([]byte, s.vectorBytes())
+	for key, locations := range s.locations {
+		for _, location := range locations {
+			err = s.readRaw(location, vector)
+			if err != nil {
+				return err
+			}
+
+			_ = binary.Write(writer, binary.LittleEndian, uint64(key))
+			_, _ = writer.Write(vector)
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write vectors file: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close vectors file: %w", err)
+	}
+
+	return nil
+}
+
+// compacted returns a new store with the vectors of this one copied to its spill file
+func (s *vectorStore) compacted() (*vectorStore, error) {
+	store := newVectorStore(s.dimensions, s.dir)
+	buf := make([]byte, s.vectorBytes())
+	vector := make(Vector, s.dimensions)
+	for key, locations := range s.locations {
+		for _, location := range locations {
+			err := s.readRaw(location, buf)
+			if err != nil {
+				_ = store.close()
+				return nil, err
+			}
+
+			for i := range vector {
+				vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+			}
+
+			next, err := store.write(vector)
+			if err != nil {
+				_ = store.close()
+				return nil, err
+			}
+			store.add(key, next)
+		}
+	}
+
+	return store, nil
+}
+
+func (s *vectorStore) close() error {
+	if s == nil {
+		return nil
+	}
+
+	var errs []error
+	if s.base != nil {
+		errs = append(errs, s.base.Close())
+	}
+	if s.spill != nil {
+		errs = append(errs, s.spill.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// loadVectorStore opens the vectors file and reads the location of its vectors, the file is kept open to read them
+func loadVectorStore(path string, dimensions uint, dir string) (*vectorStore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vectors file: %w", err)
+	}
+
+	store, err := readVectorLocations(file, dimensions, dir)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func readVectorLocations(file *os.File, dimensions uint, dir string) (*vectorStore, error) {
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(vectorsFileMagic))
+	buf := make([]byte, 12)
+
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != vectorsFileMagic {
+		return nil, fmt.Errorf("invalid vectors file: %s", file.Name())
+	}
+
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return nil, fmt.Errorf("failed to read vectors file header: %w", err)
+	}
+
+	if version := binary.LittleEndian.Uint32(buf[0:4]); version != vectorsFileVersion {
+		return nil, fmt.Errorf("unsupported vectors file version: %d", version)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vectors file: %w", err)
+	}
+
+	store := newVectorStore(dimensions, dir)
+	store.base = file
+	recordBytes := 8 + store.vectorBytes()
+	count := binary.LittleEndian.Uint64(buf[4:12])
+	if info.Size() != vectorsFileHeaderLength+int64(count)*recordBytes {
+		return nil, fmt.Errorf("invalid vectors file, expected %d vectors of %d dimensions", count, dimensions)
+	}
+
+	for i := uint64(0); i < count; i++ {
+		var key uint64
+		if err = binary.Read(reader, binary.LittleEndian, &key); err != nil {
+			return nil, fmt.Errorf("failed to read vectors file: %w", err)
+		}
+		if _, err = reader.Discard(int(store.vectorBytes())); err != nil {
+			return nil, fmt.Errorf("failed to read vectors file: %w", err)
+		}
+
+		offset := vectorsFileHeaderLength + int64(i)*recordBytes + 8
+		store.add(Key(key), vectorLocation{offset: offset})
+	}
+
+	return store, nil
+}
+
+// rescoredSearchKeys is searchKeys followed, if options.RescoreFactor is set, by the re-ranking of the candidates: the
+// index is searched for RescoreFactor times limit keys, their exact distances are computed with the full-precision
+// vectors and the closest limit keys within the max distance are returned. The caller must hold the read lock.
+func (c *Collection) rescoredSearchKeys(query Vector, limit uint32, options *SearchOptions) ([]Key, []float32, error) {
+	if options == nil || options.RescoreFactor == 0 {
+		return c.searchKeys(query, limit, options)
+	}
+
+	if c.vectors == nil {
+		return nil, nil, ErrRescoreDisabled
+	}
+
+	fetch, err := c.clampLimit(uint64(limit) * uint64(options.RescoreFactor))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys, _, err := c.searchKeys(query, fetch, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	maxDistance := options.maxDistance()
+	distances := make(map[Key]float32, len(keys))
+	rescored := make([]Key, 0, len(keys))
+	for _, key := range keys {
+		distance, stored, err := c.keyDistance(query, key)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !stored || distance > maxDistance {
+			continue
+		}
+
+		distances[key] = distance
+		rescored = append(rescored, key)
+	}
+
+	slices.SortStableFunc(rescored, func(a, b Key) int {
+		return cmp.Compare(distances[a], distances[b])
+	})
+	rescored = rescored[:min(len(rescored), int(limit))]
+
+	resultDistances := make([]float32, len(rescored))
+	for i, key := range rescored {
+		resultDistances[i] = distances[key]
+	}
+
+	return rescored, resultDistances, nil
+}
+
+// exactKeyDistance is keyDistance computed with the full-precision vectors, the caller must hold the read lock
+func (c *Collection) exactKeyDistance(query Vector, key Key) (float32, bool, error) {
+	vectors, err := c.vectors.get(key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if len(vectors) == 0 {
+		return 0, false, nil
+	}
+
+	best := float32(math.MaxFloat32)
+	for _, vector := range vectors {
+		distance, err := usearch.Distance(query, vector, c.Config.Dimensions, usearch.Metric(c.Config.Metric))
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to calculate distance: %w", err)
+		}
+
+		best = min(best, distance)
+	}
+
+	return best, true, nil
+}
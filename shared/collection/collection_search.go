@@ -0,0 +1,394 @@
+package shared_collection
+
+import (
+	"cmp"
+	"fmt"
+	usearch "github.com/unum-cloud/usearch/golang"
+	"iter"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"unsafe"
+)
+
+// scoreKeysMaxKeys is the max number of keys of an allow-list scored directly, computing the distance of each vector,
+// instead of searching the index.
+const scoreKeysMaxKeys = 8192
+
+// searchFilteredMaxRetries is the max number of times a search of a multi-vector collection is repeated when the
+// vectors of the same key fill the fetched results.
+const searchFilteredMaxRetries = 4
+
+// RadiusSearchMaxResults is the max number of keys returned by a radius search
+const RadiusSearchMaxResults = 10000
+
+// SearchResult contains the keys found ordered by distance, Metadata is set only if requested in the SearchOptions.
+// Scores is set only by the hybrid searches, the keys are then ordered by fused score, higher first.
+type SearchResult struct {
+	Keys      []Key
+	Distances []float32
+	Scores    []float32
+	Metadata  []Metadata
+}
+
+// SearchOptions changes the behaviour of Search and SearchMulti, a nil SearchOptions searches the whole index.
+// If MaxDistance is set all the keys within the distance are returned, ordered by distance, and the limit caps the
+// number of keys returned.
+// If Exact is set the distance between the query and every vector of the shard is computed instead of searching the
+// index, it requires the keys of the shard to be tracked.
+// If RescoreFactor is set RescoreFactor times limit candidates are fetched from the index and re-ranked with the exact
+// distances of the full-precision vectors, it requires the collection to have rescoring enabled.
+// If TextQuery is set the search is hybrid, the keys closest to the query are blended with the keys whose text best
+// matches TextQuery according to Fusion, TextWeight is the weight of the text score, between 0 and 1, used by
+// FusionWeightedScore.
+type SearchOptions struct {
+	KeysFilter      *KeysFilter
+	Filter          *Filter
+	IncludeMetadata bool
+	MaxDistance     *float32
+	Exact           bool
+	TextQuery       string
+	Fusion          FusionStrategy
+	TextWeight      float32
+	RescoreFactor   uint32
+}
+
+// KeysFilter restricts the search to the keys in the list or, if Exclude is set, to the keys not in the list.
+type KeysFilter struct {
+	Exclude bool
+	keys    map[Key]struct{}
+}
+
+func NewKeysFilter(keys []Key, exclude bool) *KeysFilter {
+	f := &KeysFilter{
+		Exclude: exclude,
+		keys:    make(map[Key]struct{}, len(keys)),
+	}
+	for _, key := range keys {
+		f.keys[key] = struct{}{}
+	}
+
+	return f
+}
+
+func (f *KeysFilter) Accept(key Key) bool {
+	_, found := f.keys[key]
+	return found != f.Exclude
+}
+
+// accept returns the function used to filter the results, nil if the results don't need to be filtered, the caller
+// must hold the read lock
+func (c *Collection) accept(options *SearchOptions) func(Key) bool {
+	if options == nil || (options.KeysFilter == nil && options.Filter == nil) {
+		return nil
+	}
+
+	return func(key Key) bool {
+		if options.KeysFilter != nil && !options.KeysFilter.Accept(key) {
+			return false
+		}
+
+		return options.Filter == nil || options.Filter.Accept(c.metadata.get(key))
+	}
+}
+
+// Search returns the limit keys closest to the query, if the options contain a Filter referencing fields missing from
+// the metadata of the shard, or comparing them to values of another type, the error wraps ErrInvalidFilter.
+func (c *Collection) Search(query Vector, limit uint32, options *SearchOptions) (SearchResult, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.search(query, limit, options)
+}
+
+// SearchMulti runs the queries in parallel, limits must contain either one limit shared by all the queries or one
+// limit per query, the options are shared by all the queries.
+func (c *Collection) SearchMulti(queries []Vector, limits []uint32, options *SearchOptions) ([]SearchResult, error) {
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var searchErr error
+
+	if len(limits) != 1 && len(limits) != len(queries) {
+		return nil, fmt.Errorf("expected 1 or %d limits, got %d", len(queries), len(limits))
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	results := make([]SearchResult, len(queries))
+	for i, query := range queries {
+		limit := limits[0]
+		if len(limits) > 1 {
+			limit = limits[i]
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result, err := c.search(query, limit, options)
+			if err != nil {
+				errOnce.Do(func() {
+					searchErr = fmt.Errorf("query %d: %w", i, err)
+				})
+				return
+			}
+
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	if searchErr != nil {
+		return nil, searchErr
+	}
+
+	return results, nil
+}
+
+// search runs the query on the index, the caller must hold the read lock
+func (c *Collection) search(query Vector, limit uint32, options *SearchOptions) (SearchResult, error) {
+	if options != nil && options.Filter != nil {
+		err := options.Filter.validate(c.metadata)
+		if err != nil {
+			return SearchResult{}, err
+		}
+	}
+
+	var keys []Key
+	var distances []float32
+	var scores []float32
+	var err error
+	if options != nil && options.TextQuery != "" {
+		keys, distances, scores, err = c.hybridSearch(query, limit, options)
+	} else {
+		keys, distances, err = c.rescoredSearchKeys(query, limit, options)
+	}
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	result := SearchResult{Keys: keys, Distances: distances, Scores: scores}
+	if options != nil && options.IncludeMetadata {
+		result.Metadata = make([]Metadata, len(keys))
+		for i, key := range keys {
+			result.Metadata[i] = c.metadata.get(key)
+		}
+	}
+
+	return result, nil
+}
+
+// maxDistance returns the max distance of the keys to return, +Inf if it's not a radius search
+func (o *SearchOptions) maxDistance() float32 {
+	if o == nil || o.MaxDistance == nil {
+		return float32(math.Inf(1))
+	}
+
+	return *o.MaxDistance
+}
+
+// clampLimit bounds the number of vectors fetched from the index to its length, USearch allocates the results for the
+// whole limit and fails with a limit of 0. The caller must hold the read lock.
+func (c *Collection) clampLimit(limit uint64) (uint32, error) {
+	length, err := c.index.Len()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get length of index: %w", err)
+	}
+
+	return uint32(max(min(limit, uint64(length), math.MaxUint32), 1)), nil
+}
+
+func (c *Collection) searchKeys(query Vector, limit uint32, options *SearchOptions) ([]Key, []float32, error) {
+	limit, err := c.clampLimit(uint64(limit))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	accept := c.accept(options)
+	maxDistance := options.maxDistance()
+
+	// Small allow-lists are faster to score directly than to search for in the whole index, exact searches always score
+	// the allow-list
+	if options != nil && options.KeysFilter != nil && !options.KeysFilter.Exclude &&
+		(len(options.KeysFilter.keys) <= scoreKeysMaxKeys || options.Exact) {
+		return c.scoreKeys(query, limit, maps.Keys(options.KeysFilter.keys), accept, maxDistance)
+	}
+
+	if options != nil && options.Exact {
+		if c.keys == nil {
+			return nil, nil, ErrKeysNotTracked
+		}
+
+		return c.scoreKeys(query, limit, maps.Keys(c.keys), accept, maxDistance)
+	}
+
+	c.acquireSearchSlot()
+	defer c.releaseSearchSlot()
+
+	if c.Config.Multi || accept != nil || (options != nil && options.MaxDistance != nil) {
+		return c.searchFiltered(query, limit, accept, maxDistance)
+	}
+
+	keys, distances, err := c.index.Search(query, uint(limit))
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	return *(*[]Key)(unsafe.Pointer(&keys)), distances, nil
+}
+
+// searchFiltered searches the vectors of the keys accepted by the filter, if any, with the filtered search of USearch,
+// which skips the rejected keys while traversing the graph, then drops the keys farther than maxDistance and collapses
+// the vectors stored under the same key keeping the closest one.
+// In multi-vector collections the vectors of a key can take more than one of the fetched results, the search is then
+// repeated doubling the number of fetched vectors, at most searchFilteredMaxRetries times, until there are limit keys, a
+// vector farther than maxDistance has been fetched or all the accepted vectors have been fetched.
+func (c *Collection) searchFiltered(
+	query Vector,
+	limit uint32,
+	accept func(Key) bool,
+	maxDistance float32) ([]Key, []float32, error) {
+	length, err := c.index.Len()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get length of index: %w", err)
+	}
+
+	fetch := uint(limit)
+
+	for retry := 0; ; retry++ {
+		var keys []usearch.Key
+		var distances []float32
+		if accept != nil {
+			keys, distances, err = usearchFilteredSearch(c.index, query, min(fetch, max(length, 1)), accept)
+		} else {
+			keys, distances, err = c.index.Search(query, min(fetch, max(length, 1)))
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to search: %w", err)
+		}
+
+		filteredKeys := make([]Key, 0, limit)
+		filteredDistances := make([]float32, 0, limit)
+		seen := make(map[usearch.Key]struct{}, limit)
+		outOfRange := false
+		for i, key := range keys {
+			if distances[i] > maxDistance {
+				outOfRange = true
+				break
+			}
+
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			filteredKeys = append(filteredKeys, Key(key))
+			filteredDistances = append(filteredDistances, distances[i])
+			if len(filteredKeys) == int(limit) {
+				break
+			}
+		}
+
+		if len(filteredKeys) == int(limit) || outOfRange || uint(len(keys)) < fetch || fetch >= length ||
+			retry == searchFilteredMaxRetries {
+			return filteredKeys, filteredDistances, nil
+		}
+
+		fetch *= 2
+	}
+}
+
+// scoreKeys computes the distance between the query and the vectors of the accepted keys and returns the closest limit
+// keys within maxDistance, for multi-vector collections the closest vector of each key is used.
+func (c *Collection) scoreKeys(
+	query Vector,
+	limit uint32,
+	keys iter.Seq[Key],
+	accept func(Key) bool,
+	maxDistance float32) ([]Key, []float32, error) {
+	type scoredKey struct {
+		key      Key
+		distance float32
+	}
+
+	if c.Config.Multi && c.keys == nil {
+		return nil, nil, ErrKeysNotTracked
+	}
+
+	scored := make([]scoredKey, 0, limit)
+	for key := range keys {
+		if accept != nil && !accept(key) {
+			continue
+		}
+
+		best, stored, err := c.keyDistance(query, key)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !stored || best > maxDistance {
+			continue
+		}
+
+		scored = append(scored, scoredKey{key: key, distance: best})
+	}
+
+	slices.SortFunc(scored, func(a, b scoredKey) int {
+		return cmp.Compare(a.distance, b.distance)
+	})
+	scored = scored[:min(len(scored), int(limit))]
+
+	resultKeys := make([]Key, len(scored))
+	resultDistances := make([]float32, len(scored))
+	for i, s := range scored {
+		resultKeys[i] = s.key
+		resultDistances[i] = s.distance
+	}
+
+	return resultKeys, resultDistances, nil
+}
+
+// keyDistance returns the distance between the query and the vectors of the key, for multi-vector collections the
+// distance of the closest vector, false if the key isn't stored. The full-precision vectors are used if stored.
+// The caller must hold the read lock and, for multi-vector collections, check that the keys are tracked.
+func (c *Collection) keyDistance(query Vector, key Key) (float32, bool, error) {
+	if c.vectors != nil {
+		return c.exactKeyDistance(query, key)
+	}
+
+	count := uint(1)
+	if c.Config.Multi {
+		count = uint(c.keys.count(key))
+		if count == 0 {
+			return 0, false, nil
+		}
+	}
+
+	values, err := c.index.Get(usearch.Key(key), count)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get vector from index: %w", err)
+	}
+
+	if len(values) == 0 {
+		return 0, false, nil
+	}
+
+	best := float32(math.MaxFloat32)
+	for i := uint(0); i < count; i++ {
+		distance, err := usearch.Distance(
+			query,
+			values[i*c.Config.Dimensions:(i+1)*c.Config.Dimensions],
+			c.Config.Dimensions,
+			usearch.Metric(c.Config.Metric))
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to calculate distance: %w", err)
+		}
+
+		best = min(best, distance)
+	}
+
+	return best, true, nil
+}
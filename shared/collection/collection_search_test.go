@@ -0,0 +1,74 @@
+package shared_collection
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchFiltered(t *testing.T) {
+	closest := make([]Key, 900)
+	for i := range closest {
+		closest[i] = Key(i + 1)
+	}
+	// The L2sq distances of keys 901, 902 and 903 from the query are 900², 901² and 902²
+	maxDistance := float32(812000)
+
+	tests := []struct {
+		name      string
+		configure func(config *CollectionConfig)
+		// vectors is the number of vectors added per key
+		vectors  int
+		options  *SearchOptions
+		expected []Key
+	}{
+		{
+			name:     "closest keys excluded",
+			vectors:  1,
+			options:  &SearchOptions{KeysFilter: NewKeysFilter(closest, true)},
+			expected: []Key{901, 902, 903, 904, 905},
+		},
+		{
+			name:      "multi",
+			configure: multiTestCollection,
+			vectors:   4,
+			options:   &SearchOptions{},
+			expected:  []Key{1, 2, 3, 4, 5},
+		},
+		{
+			name:      "multi with closest keys excluded",
+			configure: multiTestCollection,
+			vectors:   4,
+			options:   &SearchOptions{KeysFilter: NewKeysFilter(closest, true)},
+			expected:  []Key{901, 902, 903, 904, 905},
+		},
+		{
+			name:     "max distance",
+			vectors:  1,
+			options:  &SearchOptions{KeysFilter: NewKeysFilter(closest, true), MaxDistance: &maxDistance},
+			expected: []Key{901, 902},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestCollection(t, test.configure)
+			for key := Key(1); key <= 1000; key++ {
+				for i := 0; i < test.vectors; i++ {
+					vector := Vector{float32(key) - 1, float32(i) / 10, 0}
+					if _, err := c.Add(key, vector, nil, "", WriteModeInsert); err != nil {
+						t.Fatalf("failed to add key %d: %v", key, err)
+					}
+				}
+			}
+
+			result, err := c.Search(Vector{0, 0, 0}, 5, test.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(result.Keys, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, result.Keys)
+			}
+		})
+	}
+}
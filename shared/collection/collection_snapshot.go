@@ -0,0 +1,251 @@
+package shared_collection
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+var ErrSnapshotExists = errors.New("snapshot already exists")
+var ErrInvalidSnapshotLabel = errors.New("invalid snapshot label")
+
+var snapshotLabelPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// SnapshotInfo describes a snapshot, Size is the total size of its files in bytes
+type SnapshotInfo struct {
+	Label   string
+	SavedAt time.Time
+	Size    uint64
+}
+
+// snapshotsDirPath returns the directory holding the snapshots of the shard, each snapshot is a directory named after
+// its label containing a copy of the shard and of its manifest.
+func snapshotsDirPath(path string) string {
+	return path + ".snapshots"
+}
+
+func snapshotPath(path string, label string) string {
+	return filepath.Join(snapshotsDirPath(path), label, filepath.Base(path))
+}
+
+func validateSnapshotLabel(label string) error {
+	if strings.HasPrefix(label, ".") || !snapshotLabelPattern.MatchString(label) {
+		return fmt.Errorf("%w %q, only letters, digits, '.', '_' and '-' are allowed and it can't start with '.'",
+			ErrInvalidSnapshotLabel, label)
+	}
+
+	return nil
+}
+
+// Snapshot writes an immutable copy of the shard saved at path, with its sidecar files and its manifest, under the
+// label. The copy is written to a temporary directory renamed once complete so a partial snapshot is never listed.
+func (c *Collection) Snapshot(path string, label string) (SnapshotInfo, error) {
+	err := validateSnapshotLabel(label)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	c.saveMutex.Lock()
+	defer c.saveMutex.Unlock()
+
+	// The writes wait on writeMutex, a writer waiting for the write lock would block the new reads until the copy ends
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.readOnly.Load() {
+		return SnapshotInfo{}, ErrReadOnly
+	}
+
+	dir := filepath.Join(snapshotsDirPath(path), label)
+	_, err = os.Stat(dir)
+	if err == nil {
+		return SnapshotInfo{}, fmt.Errorf("%w: %s", ErrSnapshotExists, label)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return SnapshotInfo{}, fmt.Errorf("failed to check snapshot %s: %w", label, err)
+	}
+
+	tmpDir := filepath.Join(snapshotsDirPath(path), "."+label+".tmp")
+	err = os.RemoveAll(tmpDir)
+	if err == nil {
+		err = os.MkdirAll(tmpDir, 0755)
+	}
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	manifest, err := c.writeShard(filepath.Join(tmpDir, filepath.Base(path)))
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("failed to write snapshot %s: %w", label, err)
+	}
+
+	size, err := freezeSnapshotFiles(tmpDir)
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("failed to write snapshot %s: %w", label, err)
+	}
+
+	err = os.Rename(tmpDir, dir)
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("failed to write snapshot %s: %w", label, err)
+	}
+
+	err = syncDir(dir)
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("failed to write snapshot %s: %w", label, err)
+	}
+
+	return SnapshotInfo{Label: label, SavedAt: manifest.SavedAt, Size: size}, nil
+}
+
+// freezeSnapshotFiles makes the files of the snapshot read-only and returns their total size
+func freezeSnapshotFiles(dir string) (uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	size := uint64(0)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return 0, err
+		}
+		size += uint64(info.Size())
+
+		err = os.Chmod(filepath.Join(dir, entry.Name()), 0444)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return size, nil
+}
+
+// ListSnapshots returns the snapshots of the shard saved at path sorted from the oldest to the newest
+func (c *Collection) ListSnapshots(path string) ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(snapshotsDirPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return []SnapshotInfo{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	snapshots := make([]SnapshotInfo, 0, len(entries))
+	for _, entry := range entries {
+		// Skip the snapshots being written
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		manifest, err := LoadManifest(snapshotPath(path, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot %s: %w", entry.Name(), err)
+		}
+
+		dir := filepath.Join(snapshotsDirPath(path), entry.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot %s: %w", entry.Name(), err)
+		}
+
+		info := SnapshotInfo{Label: entry.Name(), SavedAt: manifest.SavedAt}
+		for _, file := range files {
+			fileInfo, err := file.Info()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read snapshot %s: %w", entry.Name(), err)
+			}
+			info.Size += uint64(fileInfo.Size())
+		}
+
+		snapshots = append(snapshots, info)
+	}
+
+	slices.SortFunc(snapshots, func(a, b SnapshotInfo) int {
+		return a.SavedAt.Compare(b.SavedAt)
+	})
+
+	return snapshots, nil
+}
+
+// RestoreSnapshot replaces the content of the collection with the snapshot after verifying its checksums, and saves it
+// to path. The writes made since the snapshot are lost, the write-ahead log is truncated by the save.
+func (c *Collection) RestoreSnapshot(path string, label string) error {
+	err := validateSnapshotLabel(label)
+	if err != nil {
+		return err
+	}
+
+	c.saveMutex.Lock()
+	defer c.saveMutex.Unlock()
+
+	if c.readOnly.Load() {
+		return ErrReadOnly
+	}
+
+	source := snapshotPath(path, label)
+	_, err = os.Stat(source)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrSnapshotNotFound, label)
+	} else if err != nil {
+		return fmt.Errorf("failed to check snapshot %s: %w", label, err)
+	}
+
+	// The snapshot is loaded aside and swapped in only if it has been fully loaded, a failure leaves the collection
+	// untouched
+	loaded, err := openShard(c.Config, source, false)
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", label, err)
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	err = c.replaceWith(loaded)
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", label, err)
+	}
+
+	// The content of the collection doesn't match the shard on the disk anymore until it's saved
+	c.isDirty.Store(true)
+
+	err = c.save(path)
+	if err != nil {
+		return fmt.Errorf("failed to save restored snapshot %s: %w", label, err)
+	}
+
+	return nil
+}
+
+// DeleteSnapshot removes the snapshot of the shard saved at path
+func (c *Collection) DeleteSnapshot(path string, label string) error {
+	err := validateSnapshotLabel(label)
+	if err != nil {
+		return err
+	}
+
+	c.saveMutex.Lock()
+	defer c.saveMutex.Unlock()
+
+	dir := filepath.Join(snapshotsDirPath(path), label)
+	_, err = os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrSnapshotNotFound, label)
+	} else if err != nil {
+		return fmt.Errorf("failed to check snapshot %s: %w", label, err)
+	}
+
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot %s: %w", label, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package shared_collection
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shard")
+	c := newTestCollection(t, nil)
+	if _, err := c.Add(1, Vector{1, 2, 3}, Metadata{"n": 1.0}, "", WriteModeInsert); err != nil {
+		t.Fatalf("failed to add key 1: %v", err)
+	}
+	if err := c.Save(path); err != nil {
+		t.Fatalf("failed to save shard: %v", err)
+	}
+
+	if _, err := c.Snapshot(path, "before"); err != nil {
+		t.Fatalf("failed to snapshot: %v", err)
+	}
+	if _, err := c.Snapshot(path, "before"); !errors.Is(err, ErrSnapshotExists) {
+		t.Errorf("expected ErrSnapshotExists, got %v", err)
+	}
+	if _, err := c.Snapshot(path, "../before"); !errors.Is(err, ErrInvalidSnapshotLabel) {
+		t.Errorf("expected ErrInvalidSnapshotLabel, got %v", err)
+	}
+
+	if _, err := c.Add(2, Vector{4, 5, 6}, nil, "", WriteModeInsert); err != nil {
+		t.Fatalf("failed to add key 2: %v", err)
+	}
+	if err := c.RestoreSnapshot(path, "before"); err != nil {
+		t.Fatalf("failed to restore snapshot: %v", err)
+	}
+	if !c.Has(1) || c.Has(2) || c.IsDirty() {
+		t.Errorf("expected only key 1 in a saved collection, got %t, %t, dirty %t", c.Has(1), c.Has(2), c.IsDirty())
+	}
+
+	// The restored content has been saved to the shard
+	loaded := newTestCollection(t, nil)
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("failed to load shard: %v", err)
+	}
+	if _, metadata, err := loaded.Get(1); err != nil || !loaded.Has(1) || loaded.Has(2) || metadata["n"] != 1.0 {
+		t.Errorf("expected only key 1 with its metadata in the shard, got %v and %v", metadata, err)
+	}
+
+	if err := c.DeleteSnapshot(path, "before"); err != nil {
+		t.Fatalf("failed to delete snapshot: %v", err)
+	}
+	if err := c.RestoreSnapshot(path, "before"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("expected ErrSnapshotNotFound, got %v", err)
+	}
+	if snapshots, err := c.ListSnapshots(path); err != nil || len(snapshots) != 0 {
+		t.Errorf("expected no snapshots, got %+v and %v", snapshots, err)
+	}
+}
+
+func TestSnapshotRestoreCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shard")
+	c := newTestCollection(t, nil)
+	if _, err := c.Add(1, Vector{1, 2, 3}, nil, "", WriteModeInsert); err != nil {
+		t.Fatalf("failed to add key 1: %v", err)
+	}
+	if err := c.Save(path); err != nil {
+		t.Fatalf("failed to save shard: %v", err)
+	}
+	if _, err := c.Snapshot(path, "corrupted"); err != nil {
+		t.Fatalf("failed to snapshot: %v", err)
+	}
+	if _, err := c.Add(2, Vector{4, 5, 6}, nil, "", WriteModeInsert); err != nil {
+		t.Fatalf("failed to add key 2: %v", err)
+	}
+
+	index := snapshotPath(path, "corrupted")
+	if err := os.Chmod(index, 0644); err != nil {
+		t.Fatalf("failed to make snapshot writable: %v", err)
+	}
+	if err := os.WriteFile(index, []byte("corrupted"), 0644); err != nil {
+		t.Fatalf("failed to corrupt snapshot: %v", err)
+	}
+
+	if err := c.RestoreSnapshot(path, "corrupted"); err == nil {
+		t.Fatal("expected the restore of the corrupted snapshot to fail")
+	}
+
+	// A failed restore leaves the collection untouched
+	if !c.Has(1) || !c.Has(2) {
+		t.Errorf("expected keys 1 and 2, got %t and %t", c.Has(1), c.Has(2))
+	}
+	if result, err := c.Search(Vector{4, 5, 6}, 1, nil); err != nil || len(result.Keys) != 1 || result.Keys[0] != 2 {
+		t.Errorf("expected key 2 to be found, got %+v and %v", result, err)
+	}
+}
+
+func TestSnapshotServesReadsWithWriterWaiting(t *testing.T) {
+	c := newTestCollection(t, nil)
+	if _, err := c.Add(1, Vector{1, 2, 3}, nil, "", WriteModeInsert); err != nil {
+		t.Fatalf("failed to add key 1: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "shard")
+	if err := c.Save(path); err != nil {
+		t.Fatalf("failed to save shard: %v", err)
+	}
+
+	assertReadsServedDuring(t, c, func() error {
+		_, err := c.Snapshot(path, "daily")
+		return err
+	})
+
+	snapshots, err := c.ListSnapshots(path)
+	if err != nil {
+		t.Fatalf("failed to list snapshots: %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Label != "daily" {
+		t.Errorf("expected the daily snapshot, got %+v", snapshots)
+	}
+}
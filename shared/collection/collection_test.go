@@ -0,0 +1,399 @@
+package shared_collection
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestCollection returns an empty 3 dimensions L2sq collection, configure, if not nil, can change the config
+func newTestCollection(t *testing.T, configure func(config *CollectionConfig)) *Collection {
+	t.Helper()
+
+	config := NewCollectionConfig()
+	config.Dimensions = 3
+	config.MaxSize = 1 << 20
+	config.Metric = L2sq
+	config.VectorValidation = DefaultVectorValidation(L2sq)
+	config.TempDir = t.TempDir()
+	if configure != nil {
+		configure(config)
+	}
+
+	c, err := NewCollection(config)
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Destroy() })
+
+	return c
+}
+
+func multiTestCollection(config *CollectionConfig) {
+	config.Multi = true
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(config *CollectionConfig)
+		add       []Vector
+		untracked bool
+		expected  []Vector
+	}{
+		{
+			name:     "vector",
+			add:      []Vector{{1, 2, 3}},
+			expected: []Vector{{1, 2, 3}},
+		},
+		{
+			name:     "zero vector",
+			add:      []Vector{{0, 0, 0}},
+			expected: []Vector{{0, 0, 0}},
+		},
+		{
+			name:     "missing key",
+			expected: nil,
+		},
+		{
+			name:      "multi",
+			configure: multiTestCollection,
+			add:       []Vector{{1, 2, 3}, {4, 5, 6}},
+			expected:  []Vector{{1, 2, 3}, {4, 5, 6}},
+		},
+		{
+			name:      "multi with trailing zero vector",
+			configure: multiTestCollection,
+			add:       []Vector{{1, 2, 3}, {0, 0, 0}},
+			expected:  []Vector{{1, 2, 3}, {0, 0, 0}},
+		},
+		{
+			name:      "multi with keys not tracked",
+			configure: multiTestCollection,
+			add:       []Vector{{1, 2, 3}, {0, 0, 0}},
+			untracked: true,
+			expected:  []Vector{{1, 2, 3}, {0, 0, 0}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestCollection(t, test.configure)
+			for _, vector := range test.add {
+				if _, err := c.Add(1, vector, Metadata{"n": 1.0}, "", WriteModeInsert); err != nil {
+					t.Fatalf("failed to add vector: %v", err)
+				}
+			}
+			if test.untracked {
+				c.keys = nil
+			}
+
+			vectors, metadata, err := c.Get(1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !sameVectors(vectors, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, vectors)
+			}
+			if len(test.expected) > 0 && !reflect.DeepEqual(metadata, Metadata{"n": 1.0}) {
+				t.Errorf("expected the metadata of the key, got %v", metadata)
+			}
+		})
+	}
+}
+
+func TestGetRegistryAheadOfIndex(t *testing.T) {
+	c := newTestCollection(t, multiTestCollection)
+	if _, err := c.Add(1, Vector{1, 2, 3}, nil, "", WriteModeUpsert); err != nil {
+		t.Fatalf("failed to add vector: %v", err)
+	}
+	c.keys.add(1)
+
+	vectors, _, err := c.Get(1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Only the vectors returned by the index are returned, not the ones counted by the registry
+	if expected := []Vector{{1, 2, 3}}; !sameVectors(vectors, expected) {
+		t.Errorf("expected %v, got %v", expected, vectors)
+	}
+}
+
+// sameVectors compares the vectors ignoring their order, the order of the vectors of a key isn't defined
+func sameVectors(a []Vector, b []Vector) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	used := make([]bool, len(b))
+	for _, vector := range a {
+		found := false
+		for i := range b {
+			if !used[i] && reflect.DeepEqual(vector, b[i]) {
+				used[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// assertReadsServedDuring runs long, which must rename a file while holding the collection, blocks it on its first
+// rename and checks that a search completes while a writer is waiting for long to end
+func assertReadsServedDuring(t *testing.T, c *Collection, long func() error) {
+	t.Helper()
+
+	reached := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	rename = func(from string, to string) error {
+		once.Do(func() {
+			close(reached)
+			<-release
+		})
+		return os.Rename(from, to)
+	}
+	defer func() { rename = os.Rename }()
+
+	longDone := make(chan error, 1)
+	go func() { longDone <- long() }()
+	<-reached
+
+	writeDone := make(chan error, 1)
+	go func() {
+		_, err := c.Add(100, Vector{7, 8, 9}, nil, "", WriteModeInsert)
+		writeDone <- err
+	}()
+
+	// Give the writer the time to queue behind the operation in progress
+	time.Sleep(50 * time.Millisecond)
+
+	searchDone := make(chan error, 1)
+	go func() {
+		_, err := c.Search(Vector{1, 2, 3}, 1, nil)
+		searchDone <- err
+	}()
+
+	select {
+	case err := <-searchDone:
+		if err != nil {
+			t.Errorf("failed to search: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the search has been blocked by the writer waiting for the operation in progress")
+	}
+
+	select {
+	case <-writeDone:
+		t.Error("the write completed while the operation was in progress")
+	default:
+	}
+
+	close(release)
+	if err := <-longDone; err != nil {
+		t.Errorf("the operation failed: %v", err)
+	}
+	if err := <-writeDone; err != nil {
+		t.Errorf("failed to write: %v", err)
+	}
+}
+
+func TestSaveServesReadsWithWriterWaiting(t *testing.T) {
+	c := newTestCollection(t, nil)
+	if _, err := c.Add(1, Vector{1, 2, 3}, nil, "", WriteModeInsert); err != nil {
+		t.Fatalf("failed to add key 1: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "shard")
+	assertReadsServedDuring(t, c, func() error { return c.Save(path) })
+
+	if !c.Has(100) {
+		t.Error("expected the write queued during the save to be applied")
+	}
+}
+
+func TestAddMultiCapacity(t *testing.T) {
+	tests := []struct {
+		name     string
+		count    int
+		expected bool
+	}{
+		{name: "batch fitting", count: 10, expected: false},
+		{name: "batch crossing the max size", count: 10000, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestCollection(t, func(config *CollectionConfig) { config.MaxSize = 64 << 10 })
+
+			keys := make([]Key, test.count)
+			vectors := make([]Vector, test.count)
+			for i := range keys {
+				keys[i] = Key(i + 1)
+				vectors[i] = Vector{float32(i), 0, 0}
+			}
+
+			result, err := c.AddMulti(keys, vectors, nil, nil, WriteModeInsert)
+			if err != nil {
+				t.Fatalf("failed to add vectors: %v", err)
+			}
+
+			size, err := c.index.SerializedLength()
+			if err != nil {
+				t.Fatalf("failed to get size of index: %v", err)
+			}
+			if size > c.Config.MaxSize || result.Headroom != c.Config.MaxSize-size {
+				t.Errorf("expected the size %d within the max size and its headroom, got %d", size, result.Headroom)
+			}
+			if result.IsFull != test.expected || c.IsFull() != test.expected {
+				t.Errorf("expected full %t, got %t", test.expected, result.IsFull)
+			}
+
+			// The vectors are added in order until the shard is full, the remaining ones are reported not processed
+			length, err := c.Length()
+			if err != nil {
+				t.Fatalf("failed to get length: %v", err)
+			}
+			if uint64(length) != result.Inserted {
+				t.Fatalf("expected the %d vectors of the index to be reported inserted, got %d", length, result.Inserted)
+			}
+			if (result.Inserted < uint64(test.count)) != test.expected {
+				t.Fatalf("expected full %t, got %d of %d vectors inserted", test.expected, result.Inserted, test.count)
+			}
+			for i, outcome := range result.Outcomes {
+				expected := AddOutcomeInserted
+				if uint64(i) >= result.Inserted {
+					expected = AddOutcomeNotProcessed
+				}
+				if outcome != expected {
+					t.Fatalf("key %d: expected outcome %d, got %d", keys[i], expected, outcome)
+				}
+			}
+
+			// A full shard doesn't take more vectors
+			result, err = c.AddMulti([]Key{Key(test.count + 1)}, []Vector{{1, 1, 1}}, nil, nil, WriteModeInsert)
+			if err != nil {
+				t.Fatalf("failed to add vector: %v", err)
+			}
+			if (result.Inserted == 0) != test.expected {
+				t.Errorf("expected full %t, got %d vectors inserted", test.expected, result.Inserted)
+			}
+		})
+	}
+}
+
+func TestAddWriteModes(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(config *CollectionConfig)
+		mode      WriteMode
+		// keys are added after key 1, stored with {0, 0, 0} and {"n": 0}, the i-th, from 1, with {i, i, i} and {"n": i}
+		keys             []Key
+		expectedOutcomes []AddOutcome
+		expectedVectors  []Vector
+		expectedMetadata Metadata
+	}{
+		{
+			name:             "insert new key",
+			mode:             WriteModeInsert,
+			keys:             []Key{2},
+			expectedOutcomes: []AddOutcome{AddOutcomeInserted},
+			expectedVectors:  []Vector{{0, 0, 0}},
+			expectedMetadata: Metadata{"n": 0.0},
+		},
+		{
+			name:             "insert existing key",
+			mode:             WriteModeInsert,
+			keys:             []Key{1},
+			expectedOutcomes: []AddOutcome{AddOutcomeAlreadyExists},
+			expectedVectors:  []Vector{{0, 0, 0}},
+			expectedMetadata: Metadata{"n": 0.0},
+		},
+		{
+			name:             "insert existing key in multi",
+			configure:        multiTestCollection,
+			mode:             WriteModeInsert,
+			keys:             []Key{1},
+			expectedOutcomes: []AddOutcome{AddOutcomeInserted},
+			expectedVectors:  []Vector{{0, 0, 0}, {1, 1, 1}},
+			expectedMetadata: Metadata{"n": 1.0},
+		},
+		{
+			name:             "upsert existing key",
+			mode:             WriteModeUpsert,
+			keys:             []Key{1},
+			expectedOutcomes: []AddOutcome{AddOutcomeReplaced},
+			expectedVectors:  []Vector{{1, 1, 1}},
+			expectedMetadata: Metadata{"n": 1.0},
+		},
+		{
+			name:             "upsert key repeated",
+			mode:             WriteModeUpsert,
+			keys:             []Key{1, 1},
+			expectedOutcomes: []AddOutcome{AddOutcomeReplaced, AddOutcomeReplaced},
+			expectedVectors:  []Vector{{2, 2, 2}},
+			expectedMetadata: Metadata{"n": 2.0},
+		},
+		{
+			name:             "upsert key repeated in multi",
+			configure:        multiTestCollection,
+			mode:             WriteModeUpsert,
+			keys:             []Key{1, 1},
+			expectedOutcomes: []AddOutcome{AddOutcomeReplaced, AddOutcomeInserted},
+			expectedVectors:  []Vector{{1, 1, 1}, {2, 2, 2}},
+			expectedMetadata: Metadata{"n": 2.0},
+		},
+		{
+			name:             "skip existing key",
+			mode:             WriteModeSkipIfExists,
+			keys:             []Key{1, 2},
+			expectedOutcomes: []AddOutcome{AddOutcomeSkipped, AddOutcomeInserted},
+			expectedVectors:  []Vector{{0, 0, 0}},
+			expectedMetadata: Metadata{"n": 0.0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestCollection(t, test.configure)
+			if _, err := c.Add(1, Vector{0, 0, 0}, Metadata{"n": 0.0}, "", WriteModeInsert); err != nil {
+				t.Fatalf("failed to add key 1: %v", err)
+			}
+
+			vectors := make([]Vector, len(test.keys))
+			metadata := make([]Metadata, len(test.keys))
+			for i := range test.keys {
+				vectors[i] = Vector{float32(i + 1), float32(i + 1), float32(i + 1)}
+				metadata[i] = Metadata{"n": float64(i + 1)}
+			}
+
+			result, err := c.AddMulti(test.keys, vectors, metadata, nil, test.mode)
+			if err != nil {
+				t.Fatalf("failed to add vectors: %v", err)
+			}
+			if !reflect.DeepEqual(result.Outcomes, test.expectedOutcomes) {
+				t.Errorf("expected outcomes %v, got %v", test.expectedOutcomes, result.Outcomes)
+			}
+
+			actualVectors, actualMetadata, err := c.Get(1)
+			if err != nil {
+				t.Fatalf("failed to get key 1: %v", err)
+			}
+			if !sameVectors(actualVectors, test.expectedVectors) {
+				t.Errorf("expected vectors %v, got %v", test.expectedVectors, actualVectors)
+			}
+			if !reflect.DeepEqual(actualMetadata, test.expectedMetadata) {
+				t.Errorf("expected metadata %v, got %v", test.expectedMetadata, actualMetadata)
+			}
+		})
+	}
+}
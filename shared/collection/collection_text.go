@@ -0,0 +1,212 @@
+package shared_collection
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters, k1 controls the saturation of the term frequency and b the normalization by the length of the text
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// textIndex is an inverted index of the texts stored alongside the vectors, the keys are ranked with BM25.
+// The texts are persisted in a JSON lines sidecar file next to the shard and the postings are rebuilt when it's loaded.
+type textIndex struct {
+	texts       map[Key]string
+	lengths     map[Key]uint32
+	postings    map[string]map[Key]uint32
+	totalLength uint64
+}
+
+type textFileEntry struct {
+	Key  Key    `json:"key"`
+	Text string `json:"text"`
+}
+
+func textFilePath(path string) string {
+	return path + ".text"
+}
+
+// tokenizeText lowercases the text and splits it on anything that is not a letter or a digit
+func tokenizeText(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func newTextIndex() *textIndex {
+	return &textIndex{
+		texts:    make(map[Key]string),
+		lengths:  make(map[Key]uint32),
+		postings: make(map[string]map[Key]uint32),
+	}
+}
+
+func (t *textIndex) get(key Key) string {
+	return t.texts[key]
+}
+
+func (t *textIndex) set(key Key, text string) {
+	t.remove(key)
+	if text == "" {
+		return
+	}
+
+	terms := tokenizeText(text)
+	t.texts[key] = text
+	t.lengths[key] = uint32(len(terms))
+	t.totalLength += uint64(len(terms))
+	for _, term := range terms {
+		if t.postings[term] == nil {
+			t.postings[term] = make(map[Key]uint32)
+		}
+		t.postings[term][key]++
+	}
+}
+
+func (t *textIndex) remove(key Key) {
+	text, ok := t.texts[key]
+	if !ok {
+		return
+	}
+
+	for _, term := range tokenizeText(text) {
+		delete(t.postings[term], key)
+		if len(t.postings[term]) == 0 {
+			delete(t.postings, term)
+		}
+	}
+
+	t.totalLength -= uint64(t.lengths[key])
+	delete(t.lengths, key)
+	delete(t.texts, key)
+}
+
+// queryTerms returns the distinct terms of the query
+func (t *textIndex) queryTerms(query string) []string {
+	terms := tokenizeText(query)
+	slices.Sort(terms)
+
+	return slices.Compact(terms)
+}
+
+// termScore returns the BM25 score of a term occurring frequency times in the text of the key
+func (t *textIndex) termScore(term string, key Key, frequency uint32) float64 {
+	documents := float64(len(t.texts))
+	matching := float64(len(t.postings[term]))
+	idf := math.Log(1 + (documents-matching+0.5)/(matching+0.5))
+
+	averageLength := float64(t.totalLength) / documents
+	length := float64(t.lengths[key])
+	tf := float64(frequency)
+
+	return idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*length/averageLength))
+}
+
+// score returns the BM25 score of the text of the key for the terms, 0 if the key has no text or no term matches
+func (t *textIndex) score(terms []string, key Key) float32 {
+	score := 0.0
+	for _, term := range terms {
+		if frequency, ok := t.postings[term][key]; ok {
+			score += t.termScore(term, key, frequency)
+		}
+	}
+
+	return float32(score)
+}
+
+// search returns the limit keys accepted by the filter, if any, with the highest BM25 score for the terms, the keys
+// not matching any term are not returned
+func (t *textIndex) search(terms []string, limit uint32, accept func(Key) bool) ([]Key, []float32) {
+	scores := make(map[Key]float64)
+	for _, term := range terms {
+		for key, frequency := range t.postings[term] {
+			if accept != nil && !accept(key) {
+				continue
+			}
+
+			scores[key] += t.termScore(term, key, frequency)
+		}
+	}
+
+	keys := make([]Key, 0, len(scores))
+	for key := range scores {
+		keys = append(keys, key)
+	}
+
+	// The ties are broken by key to keep the results stable
+	slices.SortFunc(keys, func(a, b Key) int {
+		if c := cmp.Compare(scores[b], scores[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	keys = keys[:min(len(keys), int(limit))]
+
+	resultScores := make([]float32, len(keys))
+	for i, key := range keys {
+		resultScores[i] = float32(scores[key])
+	}
+
+	return keys, resultScores
+}
+
+func (t *textIndex) save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create text file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for key, text := range t.texts {
+		err = encoder.Encode(textFileEntry{Key: key, Text: text})
+		if err != nil {
+			return fmt.Errorf("failed to write text file: %w", err)
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write text file: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close text file: %w", err)
+	}
+
+	return nil
+}
+
+func loadTextIndex(path string) (*textIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open text file: %w", err)
+	}
+	defer file.Close()
+
+	index := newTextIndex()
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for decoder.More() {
+		entry := textFileEntry{}
+		err = decoder.Decode(&entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read text file: %w", err)
+		}
+
+		index.set(entry.Key, entry.Text)
+	}
+
+	return index, nil
+}
@@ -0,0 +1,105 @@
+package shared_collection
+
+// The Go bindings of USearch don't return the number of vectors found by Get and don't expose the count of the
+// vectors of a key nor the filtered search, the functions below call the C library on the handle of the index
+
+/*
+#cgo LDFLAGS: -lusearch_c
+#include "usearch.h"
+
+extern int usearchAcceptKey(usearch_key_t key, void* state);
+*/
+import "C"
+
+import (
+	"errors"
+	usearch "github.com/unum-cloud/usearch/golang"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// usearchHandle returns the handle of the C index, it's the first field of usearch.Index
+func usearchHandle(index *usearch.Index) C.usearch_index_t {
+	return *(*C.usearch_index_t)(unsafe.Pointer(index))
+}
+
+// usearchCount returns the number of vectors stored under the key
+func usearchCount(index *usearch.Index, key Key) (uint, error) {
+	var errorMessage *C.char
+	count := C.usearch_count(usearchHandle(index), C.usearch_key_t(key), (*C.usearch_error_t)(&errorMessage))
+	if errorMessage != nil {
+		return 0, errors.New(C.GoString(errorMessage))
+	}
+
+	return uint(count), nil
+}
+
+// usearchGet returns up to count vectors stored under the key, only the vectors found are returned
+func usearchGet(index *usearch.Index, key Key, count uint, dimensions uint) ([]Vector, error) {
+	if count == 0 {
+		return nil, nil
+	}
+
+	values := make([]float32, count*dimensions)
+	var errorMessage *C.char
+	found := uint(C.usearch_get(
+		usearchHandle(index),
+		C.usearch_key_t(key),
+		C.size_t(count),
+		unsafe.Pointer(&values[0]),
+		C.usearch_scalar_f32_k,
+		(*C.usearch_error_t)(&errorMessage)))
+	if errorMessage != nil {
+		return nil, errors.New(C.GoString(errorMessage))
+	}
+
+	vectors := make([]Vector, min(found, count))
+	for i := range vectors {
+		vectors[i] = values[uint(i)*dimensions : uint(i+1)*dimensions]
+	}
+
+	return vectors, nil
+}
+
+// usearchFilteredSearch returns the limit vectors closest to the query among the ones whose key is accepted, the
+// filter is applied by USearch while traversing the graph
+func usearchFilteredSearch(
+	index *usearch.Index,
+	query Vector,
+	limit uint,
+	accept func(Key) bool) ([]usearch.Key, []float32, error) {
+	keys := make([]usearch.Key, limit)
+	distances := make([]float32, limit)
+
+	handle := cgo.NewHandle(accept)
+	defer handle.Delete()
+
+	var errorMessage *C.char
+	found := uint(C.usearch_filtered_search(
+		usearchHandle(index),
+		unsafe.Pointer(&query[0]),
+		C.usearch_scalar_f32_k,
+		C.size_t(limit),
+		(*[0]byte)(C.usearchAcceptKey),
+		unsafe.Pointer(&handle),
+		(*C.usearch_key_t)(unsafe.Pointer(&keys[0])),
+		(*C.usearch_distance_t)(unsafe.Pointer(&distances[0])),
+		(*C.usearch_error_t)(&errorMessage)))
+	if errorMessage != nil {
+		return nil, nil, errors.New(C.GoString(errorMessage))
+	}
+
+	return keys[:found], distances[:found], nil
+}
+
+// usearchAcceptKey is the filter called by usearch_filtered_search, state points to the handle of the accept function
+//
+//export usearchAcceptKey
+func usearchAcceptKey(key C.usearch_key_t, state unsafe.Pointer) C.int {
+	accept := (*(*cgo.Handle)(state)).Value().(func(Key) bool)
+	if accept(Key(key)) {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,11 @@
+package shared_collection
+
+// The Go bindings of USearch don't expose the version of the library
+
+// #cgo LDFLAGS: -lusearch_c
+// #include "usearch.h"
+import "C"
+
+func usearchVersion() string {
+	return C.GoString(C.usearch_version())
+}
@@ -0,0 +1,98 @@
+package shared_collection
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Validation policies of the vectors written to, and searched in, a collection.
+const (
+	// VectorValidationNone accepts any value.
+	VectorValidationNone VectorValidation = iota
+	// VectorValidationFinite refuses the vectors containing NaN or infinite values, they poison the distances.
+	VectorValidationFinite
+	// VectorValidationFiniteNonZero is VectorValidationFinite that also refuses the vectors with all the values set to
+	// 0, they have no direction and their cosine distance is meaningless.
+	VectorValidationFiniteNonZero
+)
+
+var ErrInvalidVector = errors.New("invalid vector")
+
+type VectorValidation int
+
+func ParseVectorValidation(validation string) (VectorValidation, error) {
+	switch strings.ToLower(validation) {
+	case "none":
+		return VectorValidationNone, nil
+	case "finite":
+		return VectorValidationFinite, nil
+	case "finite-non-zero":
+		return VectorValidationFiniteNonZero, nil
+	default:
+		return 0, fmt.Errorf("invalid vector validation: %s", validation)
+	}
+}
+
+// DefaultVectorValidation returns the validation used if none is configured, the zero vectors are refused only by
+// the Cosine collections
+func DefaultVectorValidation(metric Metric) VectorValidation {
+	if metric == Cosine {
+		return VectorValidationFiniteNonZero
+	}
+
+	return VectorValidationFinite
+}
+
+// String returns the name of the validation as accepted by ParseVectorValidation
+func (v VectorValidation) String() string {
+	switch v {
+	case VectorValidationNone:
+		return "none"
+	case VectorValidationFinite:
+		return "finite"
+	case VectorValidationFiniteNonZero:
+		return "finite-non-zero"
+	default:
+		return fmt.Sprintf("unknown(%d)", v)
+	}
+}
+
+// Validate checks the vectors, kind names them in the errors (e.g. vector or query). The error wraps ErrInvalidVector
+// and names the index of the first vector refused and, for NaN and infinite values, the position of the value.
+func (v VectorValidation) Validate(kind string, vectors []Vector) error {
+	for i, vector := range vectors {
+		if err := v.ValidateVector(vector); err != nil {
+			return fmt.Errorf("%s %d: %w", kind, i, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateVector checks a single vector, the error wraps ErrInvalidVector
+func (v VectorValidation) ValidateVector(vector Vector) error {
+	if v == VectorValidationNone {
+		return nil
+	}
+
+	zero := true
+	for i, value := range vector {
+		if math.IsNaN(float64(value)) {
+			return fmt.Errorf("%w: NaN at position %d", ErrInvalidVector, i)
+		}
+
+		if math.IsInf(float64(value), 0) {
+			return fmt.Errorf("%w: %g at position %d", ErrInvalidVector, value, i)
+		}
+
+		zero = zero && value == 0
+	}
+
+	if zero && v == VectorValidationFiniteNonZero {
+		return fmt.Errorf("%w: all the values are 0", ErrInvalidVector)
+	}
+
+	return nil
+}
@@ -0,0 +1,447 @@
+package shared_collection
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/danielealbano/svdb/shared/support"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const walFileMagic = "SVDBWAL1"
+const walFileVersion = uint32(1)
+const walChecksumLength = 64
+const walHeaderLength = len(walFileMagic) + 4 + walChecksumLength
+
+const (
+	walRecordAdd         = uint8(1)
+	walRecordDelete      = uint8(2)
+	walRecordDeleteMulti = uint8(3)
+	walRecordAddText     = uint8(4)
+)
+
+var walCrcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WALSyncPolicy controls when the write-ahead log is flushed to the disk: after every write, periodically or when
+// the OS decides to. Without fsync the writes survive a crash of the process but not of the machine.
+type WALSyncPolicy uint8
+
+const (
+	WALSyncAlways WALSyncPolicy = iota
+	WALSyncInterval
+	WALSyncNever
+)
+
+func ParseWALSyncPolicy(policy string) (WALSyncPolicy, error) {
+	switch strings.ToLower(policy) {
+	case "always":
+		return WALSyncAlways, nil
+	case "interval":
+		return WALSyncInterval, nil
+	case "never":
+		return WALSyncNever, nil
+	default:
+		return 0, fmt.Errorf("invalid wal sync policy: %s", policy)
+	}
+}
+
+// WALReplayResult reports the records replayed when the write-ahead log is opened, Stale is set if the log has been
+// discarded because it was written on top of a different version of the shard and DiscardedBytes is the size of the
+// incomplete record, if any, left at the end of the log by a crash.
+type WALReplayResult struct {
+	Records        uint64
+	Stale          bool
+	DiscardedBytes int64
+}
+
+// writeAheadLog is an append-only log of the writes done since the last save of the shard.
+// The header contains the checksum of the index the writes have been applied on top of, after the header each record
+// is made of its length, its CRC32 and the payload:
+// - add: record type, write mode, count and, for each vector, key, vector and JSON serialized metadata
+// - add with text: as add, with the length and the text after the metadata of each vector
+// - delete: record type and key
+// - delete multi: record type, count and keys
+type writeAheadLog struct {
+	mutex    sync.Mutex
+	file     *os.File
+	policy   WALSyncPolicy
+	syncTask *shared_support.PeriodicTask
+	syncErr  error
+}
+
+func walFilePath(path string) string {
+	return path + ".wal"
+}
+
+// OpenWAL replays the write-ahead log of the shard at path on top of the collection and records to it the writes that
+// follow, the log is created if it doesn't exist. With WALSyncInterval the log is flushed to the disk every interval.
+func (c *Collection) OpenWAL(path string, policy WALSyncPolicy, interval time.Duration) (WALReplayResult, error) {
+	var result WALReplayResult
+
+	c.lock()
+	defer c.unlock()
+
+	if c.readOnly.Load() {
+		return result, ErrReadOnly
+	}
+
+	if c.wal != nil {
+		return result, errors.New("write-ahead log already open")
+	}
+
+	file, err := os.OpenFile(walFilePath(path), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return result, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+
+	wal := &writeAheadLog{
+		file:   file,
+		policy: policy,
+	}
+
+	result, err = c.replayWAL(wal)
+	if err != nil {
+		_ = file.Close()
+		return result, err
+	}
+
+	if policy == WALSyncInterval {
+		wal.syncTask = shared_support.NewPeriodicTask(interval, wal.sync)
+		wal.syncTask.Start()
+	}
+
+	c.wal = wal
+
+	return result, nil
+}
+
+// replayWAL applies the records of the log to the collection and leaves the file ready to append the next ones, the
+// caller must hold the write lock
+func (c *Collection) replayWAL(wal *writeAheadLog) (WALReplayResult, error) {
+	var result WALReplayResult
+
+	info, err := wal.file.Stat()
+	if err != nil {
+		return result, fmt.Errorf("failed to read write-ahead log: %w", err)
+	}
+
+	// A new log, or a log written on top of another version of the shard, is reset
+	header := make([]byte, walHeaderLength)
+	_, err = io.ReadFull(wal.file, header)
+	if err != nil || !bytes.HasPrefix(header, []byte(walFileMagic)) {
+		result.Stale = info.Size() > 0
+		return result, wal.truncate(c.checksum)
+	}
+
+	if version := binary.LittleEndian.Uint32(header[len(walFileMagic):]); version != walFileVersion {
+		return result, fmt.Errorf("unsupported write-ahead log version: %d", version)
+	}
+
+	if strings.TrimRight(string(header[len(walFileMagic)+4:]), "\x00") != c.checksum {
+		result.Stale = true
+		return result, wal.truncate(c.checksum)
+	}
+
+	reader := bufio.NewReader(wal.file)
+	offset := int64(walHeaderLength)
+	for {
+		payload, err := readWALRecord(reader, info.Size()-offset)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			// The last record is incomplete or corrupted, it has been written when the process crashed and has never
+			// been acknowledged
+			result.DiscardedBytes = info.Size() - offset
+			break
+		}
+
+		err = c.applyWALRecord(payload)
+		if err != nil {
+			return result, fmt.Errorf("failed to replay write-ahead log record %d: %w", result.Records, err)
+		}
+
+		result.Records++
+		offset += int64(8 + len(payload))
+	}
+
+	err = wal.file.Truncate(offset)
+	if err != nil {
+		return result, fmt.Errorf("failed to truncate write-ahead log: %w", err)
+	}
+
+	_, err = wal.file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return result, fmt.Errorf("failed to seek write-ahead log: %w", err)
+	}
+
+	return result, nil
+}
+
+// readWALRecord reads the next record, available is the number of bytes left in the log and bounds the length of the
+// payload, a larger length can only come from a corrupted record
+func readWALRecord(reader io.Reader, available int64) ([]byte, error) {
+	header := make([]byte, 8)
+	n, err := io.ReadFull(reader, header)
+	if n == 0 && errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("incomplete record: %w", err)
+	}
+
+	length := binary.LittleEndian.Uint32(header[0:4])
+	if int64(length) > available-8 {
+		return nil, fmt.Errorf("corrupted record, length %d exceeds the %d bytes left", length, available-8)
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		return nil, fmt.Errorf("incomplete record: %w", err)
+	}
+
+	if crc32.Checksum(payload, walCrcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, errors.New("corrupted record")
+	}
+
+	return payload, nil
+}
+
+// applyWALRecord applies the record to the collection, the caller must hold the write lock
+func (c *Collection) applyWALRecord(payload []byte) error {
+	reader := bytes.NewReader(payload)
+
+	var recordType uint8
+	if err := binary.Read(reader, binary.LittleEndian, &recordType); err != nil {
+		return err
+	}
+
+	switch recordType {
+	case walRecordAdd, walRecordAddText:
+		var mode uint8
+		var count uint32
+		if err := binary.Read(reader, binary.LittleEndian, &mode); err != nil {
+			return err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+			return err
+		}
+
+		keys := make([]Key, count)
+		vectors := make([]Vector, count)
+		metadata := make([]Metadata, count)
+		var texts []string
+		if recordType == walRecordAddText {
+			texts = make([]string, count)
+		}
+		for i := range keys {
+			var metadataLength uint32
+			vectors[i] = make(Vector, c.Config.Dimensions)
+			if err := binary.Read(reader, binary.LittleEndian, &keys[i]); err != nil {
+				return err
+			}
+			if err := binary.Read(reader, binary.LittleEndian, vectors[i]); err != nil {
+				return err
+			}
+			if err := binary.Read(reader, binary.LittleEndian, &metadataLength); err != nil {
+				return err
+			}
+
+			if metadataLength > 0 {
+				data := make([]byte, metadataLength)
+				if _, err := io.ReadFull(reader, data); err != nil {
+					return err
+				}
+				if err := json.Unmarshal(data, &metadata[i]); err != nil {
+					return err
+				}
+			}
+
+			if texts != nil {
+				var textLength uint32
+				if err := binary.Read(reader, binary.LittleEndian, &textLength); err != nil {
+					return err
+				}
+
+				data := make([]byte, textLength)
+				if _, err := io.ReadFull(reader, data); err != nil {
+					return err
+				}
+				texts[i] = string(data)
+			}
+		}
+
+		_, err := c.addMulti(keys, vectors, metadata, texts, WriteMode(mode))
+		return err
+	case walRecordDelete:
+		var key Key
+		if err := binary.Read(reader, binary.LittleEndian, &key); err != nil {
+			return err
+		}
+
+		return c.delete(key)
+	case walRecordDeleteMulti:
+		var count uint32
+		if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+			return err
+		}
+
+		keys := make([]Key, count)
+		if err := binary.Read(reader, binary.LittleEndian, keys); err != nil {
+			return err
+		}
+
+		c.deleteMulti(keys)
+		return nil
+	default:
+		return fmt.Errorf("unknown record type %d", recordType)
+	}
+}
+
+// appendAdd records the vectors of a call to AddMulti, the texts, if any, are recorded with an add with text record so
+// the logs written before the text index was introduced can still be replayed
+func (w *writeAheadLog) appendAdd(
+	keys []Key,
+	vectors []Vector,
+	metadata []Metadata,
+	texts []string,
+	mode WriteMode) error {
+	var payload bytes.Buffer
+
+	recordType := walRecordAdd
+	if texts != nil {
+		recordType = walRecordAddText
+	}
+
+	payload.WriteByte(recordType)
+	payload.WriteByte(uint8(mode))
+	_ = binary.Write(&payload, binary.LittleEndian, uint32(len(keys)))
+	for i := range keys {
+		var data []byte
+		if metadata != nil && len(metadata[i]) > 0 {
+			var err error
+			data, err = json.Marshal(metadata[i])
+			if err != nil {
+				return fmt.Errorf("failed to serialize metadata: %w", err)
+			}
+		}
+
+		_ = binary.Write(&payload, binary.LittleEndian, uint64(keys[i]))
+		_ = binary.Write(&payload, binary.LittleEndian, []float32(vectors[i]))
+		_ = binary.Write(&payload, binary.LittleEndian, uint32(len(data)))
+		payload.Write(data)
+
+		if texts != nil {
+			_ = binary.Write(&payload, binary.LittleEndian, uint32(len(texts[i])))
+			payload.WriteString(texts[i])
+		}
+	}
+
+	return w.append(payload.Bytes())
+}
+
+func (w *writeAheadLog) appendDelete(key Key) error {
+	payload := make([]byte, 9)
+	payload[0] = walRecordDelete
+	binary.LittleEndian.PutUint64(payload[1:], uint64(key))
+
+	return w.append(payload)
+}
+
+func (w *writeAheadLog) appendDeleteMulti(keys []Key) error {
+	payload := make([]byte, 5+8*len(keys))
+	payload[0] = walRecordDeleteMulti
+	binary.LittleEndian.PutUint32(payload[1:], uint32(len(keys)))
+	for i, key := range keys {
+		binary.LittleEndian.PutUint64(payload[5+8*i:], uint64(key))
+	}
+
+	return w.append(payload)
+}
+
+func (w *writeAheadLog) append(payload []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.syncErr != nil {
+		return fmt.Errorf("write-ahead log failed to sync: %w", w.syncErr)
+	}
+
+	record := make([]byte, 8+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.Checksum(payload, walCrcTable))
+	copy(record[8:], payload)
+
+	_, err := w.file.Write(record)
+	if err != nil {
+		return fmt.Errorf("failed to write to write-ahead log: %w", err)
+	}
+
+	if w.policy == WALSyncAlways {
+		err = w.file.Sync()
+		if err != nil {
+			return fmt.Errorf("failed to sync write-ahead log: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// truncate empties the log and writes the header with the checksum of the index the next writes apply to
+func (w *writeAheadLog) truncate(checksum string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	header := make([]byte, walHeaderLength)
+	copy(header, walFileMagic)
+	binary.LittleEndian.PutUint32(header[len(walFileMagic):], walFileVersion)
+	copy(header[len(walFileMagic)+4:], checksum)
+
+	err := w.file.Truncate(0)
+	if err != nil {
+		return fmt.Errorf("failed to truncate write-ahead log: %w", err)
+	}
+
+	_, err = w.file.WriteAt(header, 0)
+	if err != nil {
+		return fmt.Errorf("failed to write write-ahead log header: %w", err)
+	}
+
+	_, err = w.file.Seek(int64(walHeaderLength), io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("failed to seek write-ahead log: %w", err)
+	}
+
+	return w.file.Sync()
+}
+
+// sync flushes the log to the disk, the first failure is reported by the next appends
+func (w *writeAheadLog) sync() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.file.Sync(); err != nil && w.syncErr == nil {
+		w.syncErr = err
+	}
+}
+
+func (w *writeAheadLog) close() error {
+	if w.syncTask != nil {
+		w.syncTask.Stop()
+	}
+
+	err := w.file.Sync()
+	if err != nil {
+		_ = w.file.Close()
+		return err
+	}
+
+	return w.file.Close()
+}
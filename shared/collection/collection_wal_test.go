@@ -0,0 +1,285 @@
+package shared_collection
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newWALTestCollection(t *testing.T) *Collection {
+	t.Helper()
+
+	config := NewCollectionConfig()
+	config.Dimensions = 3
+	config.MaxSize = 1 << 20
+	config.Metric = L2sq
+	config.VectorValidation = DefaultVectorValidation(L2sq)
+
+	c, err := NewCollection(config)
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Destroy() })
+
+	return c
+}
+
+func walFileSize(t *testing.T, path string) int64 {
+	t.Helper()
+
+	info, err := os.Stat(walFilePath(path))
+	if err != nil {
+		t.Fatalf("failed to stat write-ahead log: %v", err)
+	}
+
+	return info.Size()
+}
+
+// writeWALTestShard saves an empty shard at path and logs 3 adds and a delete to its write-ahead log, it returns the
+// size of the log after each record
+func writeWALTestShard(t *testing.T, path string) []int64 {
+	t.Helper()
+
+	c := newWALTestCollection(t)
+	if err := c.Save(path); err != nil {
+		t.Fatalf("failed to save shard: %v", err)
+	}
+	if _, err := c.OpenWAL(path, WALSyncAlways, 0); err != nil {
+		t.Fatalf("failed to open write-ahead log: %v", err)
+	}
+
+	var sizes []int64
+	for key := Key(1); key <= 3; key++ {
+		vector := Vector{float32(key), 0, 0}
+		if _, err := c.Add(key, vector, Metadata{"n": float64(key)}, "", WriteModeInsert); err != nil {
+			t.Fatalf("failed to add key %d: %v", key, err)
+		}
+		sizes = append(sizes, walFileSize(t, path))
+	}
+	if err := c.Delete(2); err != nil {
+		t.Fatalf("failed to delete key 2: %v", err)
+	}
+	sizes = append(sizes, walFileSize(t, path))
+
+	if err := c.Destroy(); err != nil {
+		t.Fatalf("failed to close collection: %v", err)
+	}
+
+	return sizes
+}
+
+func TestWALReplay(t *testing.T) {
+	tests := []struct {
+		name string
+		// corrupt changes the log, sizes is the size of the log after each record
+		corrupt        func(log []byte, sizes []int64) []byte
+		records        uint64
+		discardedBytes func(sizes []int64) int64
+		stale          bool
+		keys           []Key
+	}{
+		{
+			name:           "intact",
+			corrupt:        func(log []byte, sizes []int64) []byte { return log },
+			records:        4,
+			discardedBytes: func(sizes []int64) int64 { return 0 },
+			keys:           []Key{1, 3},
+		},
+		{
+			name: "truncated payload",
+			corrupt: func(log []byte, sizes []int64) []byte {
+				return log[:sizes[3]-1]
+			},
+			records:        3,
+			discardedBytes: func(sizes []int64) int64 { return sizes[3] - 1 - sizes[2] },
+			keys:           []Key{1, 2, 3},
+		},
+		{
+			name: "truncated record header",
+			corrupt: func(log []byte, sizes []int64) []byte {
+				return log[:sizes[2]+4]
+			},
+			records:        3,
+			discardedBytes: func(sizes []int64) int64 { return 4 },
+			keys:           []Key{1, 2, 3},
+		},
+		{
+			name: "corrupted payload",
+			corrupt: func(log []byte, sizes []int64) []byte {
+				log[sizes[3]-1] ^= 0xFF
+				return log
+			},
+			records:        3,
+			discardedBytes: func(sizes []int64) int64 { return sizes[3] - sizes[2] },
+			keys:           []Key{1, 2, 3},
+		},
+		{
+			name: "corrupted length",
+			corrupt: func(log []byte, sizes []int64) []byte {
+				binary.LittleEndian.PutUint32(log[sizes[2]:], 0xFFFFFFF0)
+				return log
+			},
+			records:        3,
+			discardedBytes: func(sizes []int64) int64 { return sizes[3] - sizes[2] },
+			keys:           []Key{1, 2, 3},
+		},
+		{
+			name: "corrupted middle record",
+			corrupt: func(log []byte, sizes []int64) []byte {
+				log[sizes[1]-1] ^= 0xFF
+				return log
+			},
+			records:        1,
+			discardedBytes: func(sizes []int64) int64 { return sizes[3] - sizes[0] },
+			keys:           []Key{1},
+		},
+		{
+			name: "garbage after the last record",
+			corrupt: func(log []byte, sizes []int64) []byte {
+				return append(log, 1, 2, 3)
+			},
+			records:        4,
+			discardedBytes: func(sizes []int64) int64 { return 3 },
+			keys:           []Key{1, 3},
+		},
+		{
+			name: "other version of the shard",
+			corrupt: func(log []byte, sizes []int64) []byte {
+				log[len(walFileMagic)+4] ^= 0xFF
+				return log
+			},
+			records:        0,
+			discardedBytes: func(sizes []int64) int64 { return 0 },
+			stale:          true,
+		},
+		{
+			name: "not a write-ahead log",
+			corrupt: func(log []byte, sizes []int64) []byte {
+				return []byte("garbage")
+			},
+			records:        0,
+			discardedBytes: func(sizes []int64) int64 { return 0 },
+			stale:          true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "shard")
+			sizes := writeWALTestShard(t, path)
+
+			log, err := os.ReadFile(walFilePath(path))
+			if err != nil {
+				t.Fatalf("failed to read write-ahead log: %v", err)
+			}
+			err = os.WriteFile(walFilePath(path), test.corrupt(log, sizes), 0644)
+			if err != nil {
+				t.Fatalf("failed to write write-ahead log: %v", err)
+			}
+
+			c := newWALTestCollection(t)
+			if err := c.Load(path); err != nil {
+				t.Fatalf("failed to load shard: %v", err)
+			}
+			result, err := c.OpenWAL(path, WALSyncAlways, 0)
+			if err != nil {
+				t.Fatalf("failed to replay write-ahead log: %v", err)
+			}
+
+			if result.Records != test.records {
+				t.Errorf("expected %d records replayed, got %d", test.records, result.Records)
+			}
+			if expected := test.discardedBytes(sizes); result.DiscardedBytes != expected {
+				t.Errorf("expected %d bytes discarded, got %d", expected, result.DiscardedBytes)
+			}
+			if result.Stale != test.stale {
+				t.Errorf("expected stale %v, got %v", test.stale, result.Stale)
+			}
+
+			for key := Key(1); key <= 3; key++ {
+				expected := false
+				for _, k := range test.keys {
+					expected = expected || k == key
+				}
+				if c.Has(key) != expected {
+					t.Errorf("expected key %d present %v, got %v", key, expected, c.Has(key))
+				}
+			}
+
+			// The discarded tail is truncated, the records appended after the replay follow the last valid one
+			if _, err := c.Add(10, Vector{10, 0, 0}, nil, "", WriteModeInsert); err != nil {
+				t.Fatalf("failed to add key 10: %v", err)
+			}
+			if err := c.Destroy(); err != nil {
+				t.Fatalf("failed to close collection: %v", err)
+			}
+
+			c = newWALTestCollection(t)
+			if err := c.Load(path); err != nil {
+				t.Fatalf("failed to load shard: %v", err)
+			}
+			result, err = c.OpenWAL(path, WALSyncAlways, 0)
+			if err != nil {
+				t.Fatalf("failed to replay write-ahead log: %v", err)
+			}
+
+			if result.Records != test.records+1 || result.DiscardedBytes != 0 || result.Stale {
+				t.Errorf("expected %d records replayed and nothing discarded, got %+v", test.records+1, result)
+			}
+			if !c.Has(10) {
+				t.Error("expected key 10 to be replayed")
+			}
+		})
+	}
+}
+
+func TestReadWALRecord(t *testing.T) {
+	payload := []byte{walRecordDelete, 1, 0, 0, 0, 0, 0, 0, 0}
+	record := make([]byte, 8+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.Checksum(payload, walCrcTable))
+	copy(record[8:], payload)
+
+	withLength := func(length uint32) []byte {
+		corrupted := bytes.Clone(record)
+		binary.LittleEndian.PutUint32(corrupted[0:4], length)
+		return corrupted
+	}
+
+	tests := []struct {
+		name     string
+		log      []byte
+		expected string
+	}{
+		{"valid", record, ""},
+		{"end of the log", nil, "EOF"},
+		{"truncated header", record[:5], "incomplete record"},
+		{"truncated payload", record[:len(record)-1], "corrupted record, length 9 exceeds the 8 bytes left"},
+		{"length beyond the end", withLength(10), "corrupted record, length 10 exceeds the 9 bytes left"},
+		{"huge length", withLength(0xFFFFFFF0), "corrupted record, length 4294967280 exceeds the 9 bytes left"},
+		{"checksum mismatch", append(bytes.Clone(record[:len(record)-1]), 2), "corrupted record"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			read, err := readWALRecord(bytes.NewReader(test.log), int64(len(test.log)))
+			if test.expected == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !bytes.Equal(read, payload) {
+					t.Errorf("expected payload %v, got %v", payload, read)
+				}
+				return
+			}
+
+			if err == nil || !strings.HasPrefix(err.Error(), test.expected) {
+				t.Errorf("expected an error starting with %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
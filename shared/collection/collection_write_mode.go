@@ -0,0 +1,44 @@
+package shared_collection
+
+// Write modes supported by Add and AddMulti, the values match the WriteMode enum of the collection proto.
+const (
+	// WriteModeInsert adds the vector only if the key doesn't exist, for multi-vector collections the vector is
+	// appended to the vectors of the key.
+	WriteModeInsert WriteMode = iota
+	// WriteModeUpsert replaces the vectors stored under the key.
+	WriteModeUpsert
+	// WriteModeSkipIfExists adds the vector only if the key doesn't exist, without reporting it as a failure.
+	WriteModeSkipIfExists
+)
+
+// Outcomes of the write of a key, the values match the AddOutcome enum of the collection proto.
+const (
+	// AddOutcomeNotProcessed is reported for the keys not processed because the shard got full.
+	AddOutcomeNotProcessed AddOutcome = iota
+	AddOutcomeInserted
+	AddOutcomeReplaced
+	AddOutcomeSkipped
+	AddOutcomeAlreadyExists
+)
+
+// Outcomes of the deletion of a key, the values match the DeleteOutcome enum of the collection proto.
+const (
+	// DeleteOutcomeFailed is reported for the keys USearch failed to remove.
+	DeleteOutcomeFailed DeleteOutcome = iota
+	DeleteOutcomeDeleted
+	// DeleteOutcomeMissing is reported for the keys not stored, including the repetitions of a key already deleted.
+	DeleteOutcomeMissing
+)
+
+type WriteMode int
+type AddOutcome int
+type DeleteOutcome int
+
+func (m WriteMode) IsValid() bool {
+	return m >= WriteModeInsert && m <= WriteModeSkipIfExists
+}
+
+// writes reports if the vector has been written to the index
+func (o AddOutcome) writes() bool {
+	return o == AddOutcomeInserted || o == AddOutcomeReplaced
+}
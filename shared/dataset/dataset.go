@@ -0,0 +1,169 @@
+package shared_dataset
+
+import (
+	"fmt"
+	"github.com/danielealbano/svdb/shared/collection"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Formats of the dataset files supported by Open and Create.
+const (
+	// FormatFvecs is the TEXMEX format, each vector is its dimensions as int32 followed by the float32 values.
+	FormatFvecs Format = iota
+	// FormatBvecs is FormatFvecs with uint8 values.
+	FormatBvecs
+	// FormatIvecs is FormatFvecs with int32 values.
+	FormatIvecs
+	// FormatNpy is a NumPy 2-D array, one vector per row.
+	FormatNpy
+	// FormatNpz is a NumPy archive with the vectors in the vectors array and, optionally, the keys in the keys array.
+	FormatNpz
+	// FormatNDJSON is newline-delimited JSON, each line is shaped like an add multi request:
+	// {"keys": [1, 2], "vectors": [{"values": [...]}, {"values": [...]}], "metadata": [{"lang": "en"}, {}],
+	// "texts": ["a red car", ""]}
+	FormatNDJSON
+)
+
+type Format int
+
+// Record is a vector read from, or written to, a dataset. In multi-vector collections a key has one record per vector.
+type Record struct {
+	Key      shared_collection.Key
+	Vector   shared_collection.Vector
+	Metadata shared_collection.Metadata
+	Text     string
+}
+
+// Reader reads the records of a dataset, Read returns io.EOF after the last record
+type Reader interface {
+	Read() (Record, error)
+	Close() error
+}
+
+// Writer writes the records to a dataset, the dataset is complete only once Close returns
+type Writer interface {
+	Write(record Record) error
+	Close() error
+}
+
+// ReaderOptions apply to the formats without keys, the records are numbered starting from FirstKey
+type ReaderOptions struct {
+	FirstKey shared_collection.Key
+}
+
+func ParseFormat(format string) (Format, error) {
+	switch strings.ToLower(format) {
+	case "fvecs":
+		return FormatFvecs, nil
+	case "bvecs":
+		return FormatBvecs, nil
+	case "ivecs":
+		return FormatIvecs, nil
+	case "npy":
+		return FormatNpy, nil
+	case "npz":
+		return FormatNpz, nil
+	case "ndjson", "jsonl":
+		return FormatNDJSON, nil
+	default:
+		return 0, fmt.Errorf("invalid dataset format: %s", format)
+	}
+}
+
+// FormatFromPath returns the format matching the extension of the file
+func FormatFromPath(path string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// String returns the name of the format as accepted by ParseFormat
+func (f Format) String() string {
+	switch f {
+	case FormatFvecs:
+		return "fvecs"
+	case FormatBvecs:
+		return "bvecs"
+	case FormatIvecs:
+		return "ivecs"
+	case FormatNpy:
+		return "npy"
+	case FormatNpz:
+		return "npz"
+	case FormatNDJSON:
+		return "ndjson"
+	default:
+		return fmt.Sprintf("unknown(%d)", f)
+	}
+}
+
+// HasKeys reports if the format stores the keys, the other formats store only the vectors
+func (f Format) HasKeys() bool {
+	return f == FormatNpz || f == FormatNDJSON
+}
+
+// HasMetadata reports if the format stores the metadata and the text
+func (f Format) HasMetadata() bool {
+	return f == FormatNDJSON
+}
+
+func Open(path string, format Format, options ReaderOptions) (Reader, error) {
+	if format == FormatNpz {
+		reader, err := openNpz(path, options.FirstKey)
+		if err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+
+	var reader Reader
+	switch format {
+	case FormatFvecs, FormatBvecs, FormatIvecs:
+		reader = newVecsReader(file, format, options.FirstKey)
+	case FormatNpy:
+		reader, err = newNpyReader(file, options.FirstKey)
+	case FormatNDJSON:
+		reader = newNDJSONReader(file)
+	default:
+		err = fmt.Errorf("invalid dataset format: %s", format)
+	}
+
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+func Create(path string, format Format) (Writer, error) {
+	if format == FormatNpz {
+		writer, err := createNpz(path)
+		if err != nil {
+			return nil, err
+		}
+		return writer, nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dataset: %w", err)
+	}
+
+	switch format {
+	case FormatFvecs, FormatBvecs, FormatIvecs:
+		return newVecsWriter(file, format), nil
+	case FormatNpy:
+		return newNpyWriter(file, npyDescrFloat32), nil
+	case FormatNDJSON:
+		return newNDJSONWriter(file), nil
+	}
+
+	_ = file.Close()
+	return nil, fmt.Errorf("invalid dataset format: %s", format)
+}
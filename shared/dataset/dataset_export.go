@@ -0,0 +1,54 @@
+package shared_dataset
+
+import (
+	"github.com/danielealbano/svdb/shared/collection"
+)
+
+const exportPageSize = 1000
+
+// ExportOptions select if the metadata and the text are exported, Progress, if not nil, is called with the records written after
+// every page of keys
+type ExportOptions struct {
+	IncludeMetadata bool
+	Progress        func(records uint64)
+}
+
+// Export writes every vector of the collection to the writer sorted by key, in multi-vector collections a record is
+// written for each vector of a key. The writer is not closed.
+func Export(coll *shared_collection.Collection, writer Writer, options ExportOptions) (uint64, error) {
+	records := uint64(0)
+
+	pages := coll.Export(0, shared_collection.ExportOptions{
+		PageSize:        exportPageSize,
+		IncludeVectors:  true,
+		IncludeMetadata: options.IncludeMetadata,
+		IncludeText:     options.IncludeMetadata,
+	})
+
+	for page, err := range pages {
+		if err != nil {
+			return records, err
+		}
+
+		for _, entry := range page.Entries {
+			for _, vector := range entry.Vectors {
+				err = writer.Write(Record{
+					Key:      entry.Key,
+					Vector:   vector,
+					Metadata: entry.Metadata,
+					Text:     entry.Text,
+				})
+				if err != nil {
+					return records, err
+				}
+				records++
+			}
+		}
+
+		if options.Progress != nil {
+			options.Progress(records)
+		}
+	}
+
+	return records, nil
+}
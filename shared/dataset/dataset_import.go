@@ -0,0 +1,200 @@
+package shared_dataset
+
+import (
+	"errors"
+	"fmt"
+	"github.com/danielealbano/svdb/shared/collection"
+	"io"
+)
+
+const ImportDefaultBatchSize = 1000
+
+// ImportOptions configure the shards created by Import, each shard is saved to ShardPath(BasePath, n) once full.
+// Progress, if not nil, is called after every batch.
+type ImportOptions struct {
+	Config    *shared_collection.CollectionConfig
+	BasePath  string
+	Mode      shared_collection.WriteMode
+	BatchSize int
+	Progress  func(progress ImportProgress)
+}
+
+// ImportProgress reports the records read, the vectors written and the shards saved so far
+type ImportProgress struct {
+	Records  uint64
+	Inserted uint64
+	Shards   []string
+}
+
+// ShardPath returns the path of the n-th shard created by Import
+func ShardPath(basePath string, n int) string {
+	return fmt.Sprintf("%s-%04d", basePath, n)
+}
+
+// Import adds the records of the reader to new shards in batches, when a shard reaches its max size it's saved and the
+// remaining records are added to a new one. The write mode applies to each shard, a key already stored in a previous
+// shard is added again.
+func Import(reader Reader, options ImportOptions) (ImportProgress, error) {
+	var progress ImportProgress
+
+	if options.BatchSize <= 0 {
+		options.BatchSize = ImportDefaultBatchSize
+	}
+
+	coll, err := shared_collection.NewCollection(options.Config)
+	if err != nil {
+		return progress, err
+	}
+	defer func() {
+		_ = coll.Destroy()
+	}()
+
+	for {
+		batch, readErr := readBatch(reader, options.BatchSize, options.Config, progress.Records)
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return progress, readErr
+		}
+		progress.Records += uint64(len(batch.keys))
+
+		for len(batch.keys) > 0 {
+			result, addErr := coll.AddMulti(batch.keys, batch.vectors, batch.metadata, batch.texts, options.Mode)
+			if addErr != nil {
+				return progress, fmt.Errorf("failed to add records: %w", addErr)
+			}
+			progress.Inserted += result.Inserted
+
+			if !result.IsFull {
+				break
+			}
+
+			// The shard is full, save it and add the records not processed to a new one
+			remaining := batch.notProcessed(result.Outcomes)
+			if len(remaining.keys) == 0 {
+				break
+			}
+
+			if len(remaining.keys) == len(batch.keys) {
+				length, _ := coll.Length()
+				if length == 0 {
+					return progress, errors.New("the max size of the shard is too small to store a vector")
+				}
+			}
+
+			coll, err = saveAndRollover(coll, options, &progress)
+			if err != nil {
+				return progress, err
+			}
+
+			batch = remaining
+		}
+
+		if options.Progress != nil {
+			options.Progress(progress)
+		}
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	// The last shard is saved even if empty, so an empty dataset still produces a shard
+	path := ShardPath(options.BasePath, len(progress.Shards))
+	err = coll.Save(path)
+	if err != nil {
+		return progress, fmt.Errorf("failed to save shard %s: %w", path, err)
+	}
+	progress.Shards = append(progress.Shards, path)
+
+	return progress, nil
+}
+
+func saveAndRollover(
+	coll *shared_collection.Collection,
+	options ImportOptions,
+	progress *ImportProgress) (*shared_collection.Collection, error) {
+	path := ShardPath(options.BasePath, len(progress.Shards))
+	err := coll.Save(path)
+	if err != nil {
+		return coll, fmt.Errorf("failed to save shard %s: %w", path, err)
+	}
+	progress.Shards = append(progress.Shards, path)
+
+	next, err := shared_collection.NewCollection(options.Config)
+	if err != nil {
+		return coll, err
+	}
+	_ = coll.Destroy()
+
+	return next, nil
+}
+
+type importBatch struct {
+	keys     []shared_collection.Key
+	vectors  []shared_collection.Vector
+	metadata []shared_collection.Metadata
+	texts    []string
+}
+
+// readBatch reads up to size records, checking them against the config of the collection, the error is io.EOF if the
+// end of the reader has been reached and the batch can still contain the last records
+func readBatch(
+	reader Reader,
+	size int,
+	config *shared_collection.CollectionConfig,
+	offset uint64) (importBatch, error) {
+	batch := importBatch{
+		keys:     make([]shared_collection.Key, 0, size),
+		vectors:  make([]shared_collection.Vector, 0, size),
+		metadata: make([]shared_collection.Metadata, 0, size),
+		texts:    make([]string, 0, size),
+	}
+
+	for len(batch.keys) < size {
+		record, err := reader.Read()
+		if err != nil {
+			return batch, err
+		}
+
+		if uint(len(record.Vector)) != config.Dimensions {
+			return batch, fmt.Errorf(
+				"record %d, key %d: expected %d dimensions, got %d",
+				offset+uint64(len(batch.keys)),
+				record.Key,
+				config.Dimensions,
+				len(record.Vector))
+		}
+
+		err = config.VectorValidation.ValidateVector(record.Vector)
+		if err != nil {
+			return batch, fmt.Errorf("record %d, key %d: %w", offset+uint64(len(batch.keys)), record.Key, err)
+		}
+
+		err = record.Metadata.Validate()
+		if err != nil {
+			return batch, fmt.Errorf("record %d, key %d: %w", offset+uint64(len(batch.keys)), record.Key, err)
+		}
+
+		batch.keys = append(batch.keys, record.Key)
+		batch.vectors = append(batch.vectors, record.Vector)
+		batch.metadata = append(batch.metadata, record.Metadata)
+		batch.texts = append(batch.texts, record.Text)
+	}
+
+	return batch, nil
+}
+
+// notProcessed returns the records not processed because the shard got full
+func (b importBatch) notProcessed(outcomes []shared_collection.AddOutcome) importBatch {
+	var remaining importBatch
+
+	for i, outcome := range outcomes {
+		if outcome == shared_collection.AddOutcomeNotProcessed {
+			remaining.keys = append(remaining.keys, b.keys[i])
+			remaining.vectors = append(remaining.vectors, b.vectors[i])
+			remaining.metadata = append(remaining.metadata, b.metadata[i])
+			remaining.texts = append(remaining.texts, b.texts[i])
+		}
+	}
+
+	return remaining
+}
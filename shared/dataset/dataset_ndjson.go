@@ -0,0 +1,187 @@
+package shared_dataset
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/danielealbano/svdb/shared/collection"
+	"io"
+	"os"
+)
+
+// ndjsonLineRecords is the number of records written per line
+const ndjsonLineRecords = 1000
+
+// ndjsonMaxLineLength bounds the length of a line, a line of 1000 vectors of 1536 dimensions is about 20MB
+const ndjsonMaxLineLength = 256 << 20
+
+type ndjsonVector struct {
+	Values []float32 `json:"values"`
+}
+
+// ndjsonLine has the shape of an add multi request, the metadata and the texts are either empty or have the same
+// length of the keys
+type ndjsonLine struct {
+	Keys     []uint64                     `json:"keys"`
+	Vectors  []ndjsonVector               `json:"vectors"`
+	Metadata []shared_collection.Metadata `json:"metadata,omitempty"`
+	Texts    []string                     `json:"texts,omitempty"`
+}
+
+type ndjsonReader struct {
+	file    *os.File
+	scanner *bufio.Scanner
+	line    int
+	pending ndjsonLine
+	next    int
+}
+
+type ndjsonWriter struct {
+	file   *os.File
+	writer *bufio.Writer
+	line   ndjsonLine
+}
+
+func newNDJSONReader(file *os.File) *ndjsonReader {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), ndjsonMaxLineLength)
+
+	return &ndjsonReader{
+		file:    file,
+		scanner: scanner,
+	}
+}
+
+func (r *ndjsonReader) Read() (Record, error) {
+	for r.next >= len(r.pending.Keys) {
+		err := r.readLine()
+		if err != nil {
+			return Record{}, err
+		}
+	}
+
+	record := Record{
+		Key:    shared_collection.Key(r.pending.Keys[r.next]),
+		Vector: r.pending.Vectors[r.next].Values,
+	}
+	if len(r.pending.Metadata) > 0 {
+		record.Metadata = r.pending.Metadata[r.next]
+	}
+	if len(r.pending.Texts) > 0 {
+		record.Text = r.pending.Texts[r.next]
+	}
+	r.next++
+
+	return record, nil
+}
+
+// readLine reads the next non-empty line, io.EOF at the end of the file
+func (r *ndjsonReader) readLine() error {
+	for {
+		if !r.scanner.Scan() {
+			if err := r.scanner.Err(); err != nil {
+				return fmt.Errorf("failed to read line %d: %w", r.line+1, err)
+			}
+			return io.EOF
+		}
+		r.line++
+
+		if len(r.scanner.Bytes()) > 0 {
+			break
+		}
+	}
+
+	r.pending = ndjsonLine{}
+	r.next = 0
+
+	err := json.Unmarshal(r.scanner.Bytes(), &r.pending)
+	if err != nil {
+		return fmt.Errorf("failed to parse line %d: %w", r.line, err)
+	}
+
+	if len(r.pending.Keys) != len(r.pending.Vectors) {
+		return fmt.Errorf("line %d: keys and vectors must have the same length", r.line)
+	}
+
+	if len(r.pending.Metadata) > 0 && len(r.pending.Metadata) != len(r.pending.Keys) {
+		return fmt.Errorf("line %d: keys and metadata must have the same length", r.line)
+	}
+
+	if len(r.pending.Texts) > 0 && len(r.pending.Texts) != len(r.pending.Keys) {
+		return fmt.Errorf("line %d: keys and texts must have the same length", r.line)
+	}
+
+	return nil
+}
+
+func (r *ndjsonReader) Close() error {
+	return r.file.Close()
+}
+
+func newNDJSONWriter(file *os.File) *ndjsonWriter {
+	return &ndjsonWriter{
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}
+}
+
+func (w *ndjsonWriter) Write(record Record) error {
+	// The metadata and the texts are written only if at least a record of the line has them
+	if len(record.Metadata) > 0 && w.line.Metadata == nil {
+		w.line.Metadata = make([]shared_collection.Metadata, len(w.line.Keys), ndjsonLineRecords)
+	}
+	if record.Text != "" && w.line.Texts == nil {
+		w.line.Texts = make([]string, len(w.line.Keys), ndjsonLineRecords)
+	}
+
+	w.line.Keys = append(w.line.Keys, uint64(record.Key))
+	w.line.Vectors = append(w.line.Vectors, ndjsonVector{Values: record.Vector})
+	if w.line.Metadata != nil {
+		w.line.Metadata = append(w.line.Metadata, record.Metadata)
+	}
+	if w.line.Texts != nil {
+		w.line.Texts = append(w.line.Texts, record.Text)
+	}
+
+	if len(w.line.Keys) == ndjsonLineRecords {
+		return w.flushLine()
+	}
+
+	return nil
+}
+
+func (w *ndjsonWriter) flushLine() error {
+	if len(w.line.Keys) == 0 {
+		return nil
+	}
+
+	// A nil metadata is written as null, an empty object is what the readers expect for the keys without metadata
+	for i, m := range w.line.Metadata {
+		if m == nil {
+			w.line.Metadata[i] = shared_collection.Metadata{}
+		}
+	}
+
+	data, err := json.Marshal(&w.line)
+	if err != nil {
+		return fmt.Errorf("failed to serialize line: %w", err)
+	}
+
+	w.line = ndjsonLine{}
+
+	_, err = w.writer.Write(append(data, '\n'))
+	return err
+}
+
+func (w *ndjsonWriter) Close() error {
+	err := w.flushLine()
+	if err == nil {
+		err = w.writer.Flush()
+	}
+	if err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("failed to write dataset: %w", err)
+	}
+
+	return w.file.Close()
+}
@@ -0,0 +1,374 @@
+package shared_dataset
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/danielealbano/svdb/shared/collection"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const npyMagic = "\x93NUMPY"
+
+// npyHeaderLength is the length of the header written by npyWriter, large enough for any shape so the header can be
+// rewritten in place once the number of rows is known
+const npyHeaderLength = 128
+
+// npyMaxHeaderLength caps the header length read from the files, the headers written by numpy are a few hundred bytes
+const npyMaxHeaderLength = 64 * 1024
+
+// npyMaxColumns bounds the columns read from the shape to not allocate garbage on a corrupted file, as
+// vecsMaxDimensions does for the vecs files
+const npyMaxColumns = 1 << 16
+
+const (
+	npyDescrFloat32 = "<f4"
+	npyDescrUint64  = "<u8"
+)
+
+var npyDescrPattern = regexp.MustCompile(`'descr':\s*'([^']*)'`)
+var npyFortranOrderPattern = regexp.MustCompile(`'fortran_order':\s*(True|False)`)
+var npyShapePattern = regexp.MustCompile(`'shape':\s*\(([^)]*)\)`)
+
+// npyArray reads the rows of a 1-D or 2-D little or big endian numeric array in C order
+type npyArray struct {
+	reader  io.Reader
+	kind    byte
+	size    int
+	order   binary.ByteOrder
+	rows    uint64
+	columns uint64
+	row     uint64
+	buf     []byte
+}
+
+type npyReader struct {
+	array  *npyArray
+	closer io.Closer
+	key    shared_collection.Key
+}
+
+// npyWriter writes a 2-D array, or a 1-D one if the columns are 0, the header is rewritten with the number of rows when
+// the writer is closed
+type npyWriter struct {
+	file    *os.File
+	writer  *bufio.Writer
+	descr   string
+	rows    uint64
+	columns uint64
+	buf     []byte
+}
+
+func readNpyHeader(reader io.Reader) (*npyArray, error) {
+	var headerLength uint32
+
+	preamble := make([]byte, len(npyMagic)+2)
+	_, err := io.ReadFull(reader, preamble)
+	if err != nil || string(preamble[:len(npyMagic)]) != npyMagic {
+		return nil, errors.New("not a npy file")
+	}
+
+	// The version 1 has a 2 bytes header length, the following ones a 4 bytes one
+	if preamble[len(npyMagic)] == 1 {
+		var length uint16
+		err = binary.Read(reader, binary.LittleEndian, &length)
+		headerLength = uint32(length)
+	} else {
+		err = binary.Read(reader, binary.LittleEndian, &headerLength)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read npy header: %w", err)
+	}
+	if headerLength > npyMaxHeaderLength {
+		return nil, fmt.Errorf("npy header too long, %d bytes, expected at most %d", headerLength, npyMaxHeaderLength)
+	}
+
+	header := make([]byte, headerLength)
+	_, err = io.ReadFull(reader, header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read npy header: %w", err)
+	}
+
+	return parseNpyHeader(reader, string(header))
+}
+
+func parseNpyHeader(reader io.Reader, header string) (*npyArray, error) {
+	var err error
+
+	descr := npyDescrPattern.FindStringSubmatch(header)
+	fortranOrder := npyFortranOrderPattern.FindStringSubmatch(header)
+	shape := npyShapePattern.FindStringSubmatch(header)
+	if descr == nil || fortranOrder == nil || shape == nil {
+		return nil, fmt.Errorf("invalid npy header: %s", strings.TrimSpace(header))
+	}
+
+	if fortranOrder[1] == "True" {
+		return nil, errors.New("npy arrays in fortran order are not supported")
+	}
+
+	array := &npyArray{reader: reader, order: binary.LittleEndian}
+
+	if len(descr[1]) < 3 {
+		return nil, fmt.Errorf("unsupported npy type %s", descr[1])
+	}
+	if descr[1][0] == '>' {
+		array.order = binary.BigEndian
+	}
+	array.kind = descr[1][1]
+	array.size, err = strconv.Atoi(descr[1][2:])
+	if err != nil {
+		return nil, fmt.Errorf("unsupported npy type %s", descr[1])
+	}
+
+	switch {
+	case array.kind == 'f' && (array.size == 4 || array.size == 8):
+	case (array.kind == 'i' || array.kind == 'u') &&
+		(array.size == 1 || array.size == 2 || array.size == 4 || array.size == 8):
+	default:
+		return nil, fmt.Errorf("unsupported npy type %s", descr[1])
+	}
+
+	var dimensions []uint64
+	for _, dimension := range strings.Split(shape[1], ",") {
+		dimension = strings.TrimSpace(dimension)
+		if dimension == "" {
+			continue
+		}
+
+		value, err := strconv.ParseUint(dimension, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid npy shape (%s)", shape[1])
+		}
+		dimensions = append(dimensions, value)
+	}
+
+	switch len(dimensions) {
+	case 1:
+		array.rows, array.columns = dimensions[0], 1
+	case 2:
+		array.rows, array.columns = dimensions[0], dimensions[1]
+	default:
+		return nil, fmt.Errorf("npy arrays with shape (%s) are not supported, expected 1 or 2 dimensions", shape[1])
+	}
+	// The empty arrays have no rows to read, e.g. the (0, 0) written by npyWriter when no records have been written
+	if array.columns == 0 && array.rows > 0 {
+		return nil, fmt.Errorf("npy arrays with shape (%s) are not supported, expected at least 1 column", shape[1])
+	}
+	if array.columns > npyMaxColumns || array.columns > math.MaxInt/uint64(array.size) {
+		return nil, fmt.Errorf(
+			"npy arrays with shape (%s) are not supported, expected at most %d columns", shape[1], npyMaxColumns)
+	}
+
+	array.buf = make([]byte, array.columns*uint64(array.size))
+
+	return array, nil
+}
+
+// readRow reads the next row, io.EOF after the last one
+func (a *npyArray) readRow() error {
+	if a.row == a.rows {
+		return io.EOF
+	}
+
+	_, err := io.ReadFull(a.reader, a.buf)
+	if err != nil {
+		return fmt.Errorf("failed to read row %d of npy array: %w", a.row, io.ErrUnexpectedEOF)
+	}
+	a.row++
+
+	return nil
+}
+
+func (a *npyArray) float(i int) float32 {
+	value := a.buf[i*a.size : (i+1)*a.size]
+
+	switch {
+	case a.kind == 'f' && a.size == 4:
+		return math.Float32frombits(a.order.Uint32(value))
+	case a.kind == 'f':
+		return float32(math.Float64frombits(a.order.Uint64(value)))
+	case a.kind == 'i':
+		return float32(a.integer(value))
+	default:
+		return float32(a.unsigned(value))
+	}
+}
+
+func (a *npyArray) integer(value []byte) int64 {
+	switch a.size {
+	case 1:
+		return int64(int8(value[0]))
+	case 2:
+		return int64(int16(a.order.Uint16(value)))
+	case 4:
+		return int64(int32(a.order.Uint32(value)))
+	default:
+		return int64(a.order.Uint64(value))
+	}
+}
+
+func (a *npyArray) unsigned(value []byte) uint64 {
+	switch a.size {
+	case 1:
+		return uint64(value[0])
+	case 2:
+		return uint64(a.order.Uint16(value))
+	case 4:
+		return uint64(a.order.Uint32(value))
+	default:
+		return a.order.Uint64(value)
+	}
+}
+
+func (a *npyArray) readVector() (shared_collection.Vector, error) {
+	err := a.readRow()
+	if err != nil {
+		return nil, err
+	}
+
+	vector := make(shared_collection.Vector, a.columns)
+	for i := range vector {
+		vector[i] = a.float(i)
+	}
+
+	return vector, nil
+}
+
+func (a *npyArray) readKey() (shared_collection.Key, error) {
+	if a.columns != 1 || a.kind == 'f' {
+		return 0, errors.New("the keys must be a 1-D array of integers")
+	}
+
+	err := a.readRow()
+	if err != nil {
+		return 0, err
+	}
+
+	if a.kind == 'i' {
+		return shared_collection.Key(a.integer(a.buf)), nil
+	}
+
+	return shared_collection.Key(a.unsigned(a.buf)), nil
+}
+
+func newNpyReader(file *os.File, firstKey shared_collection.Key) (*npyReader, error) {
+	array, err := readNpyHeader(bufio.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+
+	return &npyReader{array: array, closer: file, key: firstKey}, nil
+}
+
+func (r *npyReader) Read() (Record, error) {
+	vector, err := r.array.readVector()
+	if err != nil {
+		return Record{}, err
+	}
+
+	record := Record{Key: r.key, Vector: vector}
+	r.key++
+
+	return record, nil
+}
+
+func (r *npyReader) Close() error {
+	return r.closer.Close()
+}
+
+func newNpyWriter(file *os.File, descr string) *npyWriter {
+	return &npyWriter{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		descr:  descr,
+	}
+}
+
+func (w *npyWriter) header() []byte {
+	shape := fmt.Sprintf("(%d,)", w.rows)
+	if w.descr == npyDescrFloat32 {
+		shape = fmt.Sprintf("(%d, %d)", w.rows, w.columns)
+	}
+
+	header := make([]byte, 0, npyHeaderLength)
+	header = append(header, npyMagic...)
+	header = append(header, 1, 0)
+	header = binary.LittleEndian.AppendUint16(header, npyHeaderLength-uint16(len(npyMagic))-4)
+	header = fmt.Appendf(header, "{'descr': '%s', 'fortran_order': False, 'shape': %s, }", w.descr, shape)
+	for len(header) < npyHeaderLength-1 {
+		header = append(header, ' ')
+	}
+
+	return append(header, '\n')
+}
+
+func (w *npyWriter) Write(record Record) error {
+	if w.rows == 0 {
+		w.columns = uint64(len(record.Vector))
+		_, err := w.writer.Write(w.header())
+		if err != nil {
+			return err
+		}
+	}
+
+	if uint64(len(record.Vector)) != w.columns {
+		return fmt.Errorf("expected vectors with %d dimensions, got %d", w.columns, len(record.Vector))
+	}
+
+	w.buf = w.buf[:0]
+	for _, v := range record.Vector {
+		w.buf = binary.LittleEndian.AppendUint32(w.buf, math.Float32bits(v))
+	}
+	w.rows++
+
+	_, err := w.writer.Write(w.buf)
+	return err
+}
+
+func (w *npyWriter) writeKey(key shared_collection.Key) error {
+	if w.rows == 0 {
+		_, err := w.writer.Write(w.header())
+		if err != nil {
+			return err
+		}
+	}
+	w.rows++
+
+	w.buf = binary.LittleEndian.AppendUint64(w.buf[:0], uint64(key))
+	_, err := w.writer.Write(w.buf)
+	return err
+}
+
+// finish writes the header with the final shape, an empty array is written if no rows have been written
+func (w *npyWriter) finish() error {
+	if w.rows == 0 {
+		_, err := w.writer.Write(w.header())
+		if err != nil {
+			return err
+		}
+	}
+
+	err := w.writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.file.WriteAt(w.header(), 0)
+	return err
+}
+
+func (w *npyWriter) Close() error {
+	err := w.finish()
+	if err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("failed to write dataset: %w", err)
+	}
+
+	return w.file.Close()
+}
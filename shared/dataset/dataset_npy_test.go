@@ -0,0 +1,154 @@
+package shared_dataset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/danielealbano/svdb/shared/collection"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// npyTestFile returns a npy file with the header and the data, version 1 has a 2 bytes header length and the following
+// ones a 4 bytes one
+func npyTestFile(version byte, header string, data []byte) []byte {
+	file := append([]byte(npyMagic), version, 0)
+	if version == 1 {
+		file = binary.LittleEndian.AppendUint16(file, uint16(len(header)))
+	} else {
+		file = binary.LittleEndian.AppendUint32(file, uint32(len(header)))
+	}
+	file = append(file, header...)
+
+	return append(file, data...)
+}
+
+func npyTestHeader(descr string, shape string) string {
+	return "{'descr': '" + descr + "', 'fortran_order': False, 'shape': " + shape + ", }\n"
+}
+
+func TestReadNpyHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    []byte
+		rows    uint64
+		columns uint64
+		err     string
+	}{
+		{name: "2-D", file: npyTestFile(1, npyTestHeader("<f4", "(3, 4)"), nil), rows: 3, columns: 4},
+		{name: "1-D", file: npyTestFile(1, npyTestHeader("<u8", "(3,)"), nil), rows: 3, columns: 1},
+		{name: "version 2", file: npyTestFile(2, npyTestHeader(">f8", "(2, 5)"), nil), rows: 2, columns: 5},
+		{name: "empty", file: npyTestFile(1, npyTestHeader("<f4", "(0, 0)"), nil), rows: 0, columns: 0},
+		{
+			name: "no columns",
+			file: npyTestFile(1, npyTestHeader("<f4", "(5, 0)"), nil),
+			err:  "npy arrays with shape (5, 0) are not supported, expected at least 1 column",
+		},
+		{name: "max columns", file: npyTestFile(1, npyTestHeader("<f8", "(1, 65536)"), nil), rows: 1, columns: 65536},
+		{
+			name: "too many columns",
+			file: npyTestFile(1, npyTestHeader("<f8", "(1, 65537)"), nil),
+			err:  "npy arrays with shape (1, 65537) are not supported, expected at most 65536 columns",
+		},
+		{
+			name: "huge shape",
+			file: npyTestFile(1, npyTestHeader("<f8", "(18446744073709551615, 18446744073709551615)"), nil),
+			err:  "npy arrays with shape (18446744073709551615, 18446744073709551615) are not supported",
+		},
+		{
+			name: "3-D",
+			file: npyTestFile(1, npyTestHeader("<f4", "(2, 3, 4)"), nil),
+			err:  "npy arrays with shape (2, 3, 4) are not supported, expected 1 or 2 dimensions",
+		},
+		{
+			name: "scalar",
+			file: npyTestFile(1, npyTestHeader("<f4", "()"), nil),
+			err:  "npy arrays with shape () are not supported, expected 1 or 2 dimensions",
+		},
+		{
+			name: "invalid shape",
+			file: npyTestFile(1, npyTestHeader("<f4", "(a, 4)"), nil),
+			err:  "invalid npy shape (a, 4)",
+		},
+		{
+			name: "fortran order",
+			file: npyTestFile(1, "{'descr': '<f4', 'fortran_order': True, 'shape': (3, 4), }\n", nil),
+			err:  "npy arrays in fortran order are not supported",
+		},
+		{name: "complex", file: npyTestFile(1, npyTestHeader("<c8", "(3, 4)"), nil), err: "unsupported npy type <c8"},
+		{name: "half", file: npyTestFile(1, npyTestHeader("<f2", "(3, 4)"), nil), err: "unsupported npy type <f2"},
+		{name: "short descr", file: npyTestFile(1, npyTestHeader("f", "(3, 4)"), nil), err: "unsupported npy type f"},
+		{
+			name: "missing descr",
+			file: npyTestFile(1, "{'fortran_order': False, 'shape': (3, 4), }\n", nil),
+			err:  "invalid npy header",
+		},
+		{
+			name: "header too long",
+			file: npyTestFile(2, strings.Repeat(" ", npyMaxHeaderLength+1), nil),
+			err:  "npy header too long, 65537 bytes, expected at most 65536",
+		},
+		{
+			name: "truncated header",
+			file: npyTestFile(1, npyTestHeader("<f4", "(3, 4)"), nil)[:20],
+			err:  "failed to read npy header",
+		},
+		{name: "not npy", file: []byte("\x93NUMPX\x01\x00"), err: "not a npy file"},
+		{name: "empty file", file: nil, err: "not a npy file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			array, err := readNpyHeader(bytes.NewReader(test.file))
+			if test.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+					t.Fatalf("expected an error starting with %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if array.rows != test.rows || array.columns != test.columns {
+				t.Errorf("expected shape (%d, %d), got (%d, %d)", test.rows, test.columns, array.rows, array.columns)
+			}
+		})
+	}
+}
+
+func TestNpyArrayValues(t *testing.T) {
+	tests := []struct {
+		descr    string
+		data     []byte
+		expected shared_collection.Vector
+	}{
+		{"<f4", binary.LittleEndian.AppendUint32(nil, 0x3FC00000), shared_collection.Vector{1.5}},
+		{">f4", binary.BigEndian.AppendUint32(nil, 0x3FC00000), shared_collection.Vector{1.5}},
+		{">f8", binary.BigEndian.AppendUint64(nil, 0x3FF8000000000000), shared_collection.Vector{1.5}},
+		{"<i2", binary.LittleEndian.AppendUint16(nil, 0xFFFE), shared_collection.Vector{-2}},
+		{">i4", binary.BigEndian.AppendUint32(nil, 0xFFFFFFFD), shared_collection.Vector{-3}},
+		{"|i1", []byte{0xFF}, shared_collection.Vector{-1}},
+		{"|u1", []byte{0xFF}, shared_collection.Vector{255}},
+		{"<u2", binary.LittleEndian.AppendUint16(nil, 0xFFFE), shared_collection.Vector{65534}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.descr, func(t *testing.T) {
+			file := npyTestFile(1, npyTestHeader(test.descr, "(1, 1)"), test.data)
+
+			array, err := readNpyHeader(bytes.NewReader(file))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			vector, err := array.readVector()
+			if err != nil {
+				t.Fatalf("failed to read vector: %v", err)
+			}
+			if !reflect.DeepEqual(vector, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, vector)
+			}
+		})
+	}
+}
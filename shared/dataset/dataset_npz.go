@@ -0,0 +1,220 @@
+package shared_dataset
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/danielealbano/svdb/shared/collection"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	npzVectorsName = "vectors.npy"
+	npzKeysName    = "keys.npy"
+)
+
+type npzReader struct {
+	archive *zip.ReadCloser
+	files   []io.Closer
+	vectors *npyArray
+	keys    *npyArray
+	key     shared_collection.Key
+}
+
+// npzWriter writes the vectors and the keys to temporary npy files, copied in the archive when the writer is closed
+// as the entries of a zip archive are written one after the other.
+type npzWriter struct {
+	path    string
+	vectors *npyWriter
+	keys    *npyWriter
+}
+
+// openNpz opens the vectors array, or the only array of the archive if there isn't one named vectors as np.savez names
+// the arrays passed without a name arr_0, arr_1, etc., and the keys array if present.
+func openNpz(path string, firstKey shared_collection.Key) (*npzReader, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+
+	var vectorsFile, keysFile *zip.File
+	var arrays []*zip.File
+	for _, f := range archive.File {
+		switch {
+		case f.Name == npzVectorsName:
+			vectorsFile = f
+		case f.Name == npzKeysName:
+			keysFile = f
+		case strings.HasSuffix(f.Name, ".npy"):
+			arrays = append(arrays, f)
+		}
+	}
+
+	if vectorsFile == nil && len(arrays) == 1 {
+		vectorsFile = arrays[0]
+	}
+
+	if vectorsFile == nil {
+		_ = archive.Close()
+		return nil, fmt.Errorf("the npz archive has no %s array", strings.TrimSuffix(npzVectorsName, ".npy"))
+	}
+
+	r := &npzReader{archive: archive, key: firstKey}
+
+	r.vectors, err = r.openArray(vectorsFile)
+	if err == nil && keysFile != nil {
+		r.keys, err = r.openArray(keysFile)
+		if err == nil && r.keys.rows != r.vectors.rows {
+			err = fmt.Errorf("the npz archive has %d keys and %d vectors", r.keys.rows, r.vectors.rows)
+		}
+	}
+
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *npzReader) openArray(f *zip.File) (*npyArray, error) {
+	file, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	r.files = append(r.files, file)
+
+	array, err := readNpyHeader(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+
+	return array, nil
+}
+
+func (r *npzReader) Read() (Record, error) {
+	vector, err := r.vectors.readVector()
+	if err != nil {
+		return Record{}, err
+	}
+
+	record := Record{Key: r.key, Vector: vector}
+	r.key++
+
+	if r.keys != nil {
+		record.Key, err = r.keys.readKey()
+		if err != nil {
+			return Record{}, err
+		}
+	}
+
+	return record, nil
+}
+
+func (r *npzReader) Close() error {
+	var err error
+	for _, file := range r.files {
+		err = errors.Join(err, file.Close())
+	}
+
+	return errors.Join(err, r.archive.Close())
+}
+
+func createNpz(path string) (*npzWriter, error) {
+	w := &npzWriter{path: path}
+
+	for _, target := range []**npyWriter{&w.vectors, &w.keys} {
+		file, err := os.CreateTemp("", "svdb-npz-*.npy")
+		if err != nil {
+			w.discard()
+			return nil, fmt.Errorf("failed to create dataset: %w", err)
+		}
+
+		descr := npyDescrFloat32
+		if target == &w.keys {
+			descr = npyDescrUint64
+		}
+		*target = newNpyWriter(file, descr)
+	}
+
+	return w, nil
+}
+
+func (w *npzWriter) Write(record Record) error {
+	err := w.vectors.Write(record)
+	if err != nil {
+		return err
+	}
+
+	return w.keys.writeKey(record.Key)
+}
+
+func (w *npzWriter) Close() error {
+	defer w.discard()
+
+	err := w.vectors.finish()
+	if err == nil {
+		err = w.keys.finish()
+	}
+	if err == nil {
+		err = w.writeArchive()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write dataset: %w", err)
+	}
+
+	return nil
+}
+
+func (w *npzWriter) writeArchive() error {
+	file, err := os.Create(w.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	for _, array := range []struct {
+		name   string
+		writer *npyWriter
+	}{
+		{name: npzVectorsName, writer: w.vectors},
+		{name: npzKeysName, writer: w.keys},
+	} {
+		// The arrays are stored without compression like np.savez does
+		entry, err := archive.CreateHeader(&zip.FileHeader{Name: array.name, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+
+		_, err = array.writer.file.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(entry, array.writer.file)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// discard removes the temporary files
+func (w *npzWriter) discard() {
+	for _, writer := range []*npyWriter{w.vectors, w.keys} {
+		if writer != nil {
+			_ = writer.file.Close()
+			_ = os.Remove(writer.file.Name())
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package shared_dataset
+
+import (
+	"errors"
+	"fmt"
+	"github.com/danielealbano/svdb/shared/collection"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testFormats = []Format{FormatFvecs, FormatBvecs, FormatIvecs, FormatNpy, FormatNpz, FormatNDJSON}
+
+// testRecords returns count records with the keys starting from 10, the values are integers between 0 and 255 so
+// every format stores them exactly, the metadata and the text are set only if the format stores them
+func testRecords(format Format, count int) []Record {
+	records := make([]Record, count)
+	for i := range records {
+		records[i] = Record{
+			Key:    shared_collection.Key(10 + i),
+			Vector: shared_collection.Vector{float32(i), float32(2 * i), 255, float32(i%3 + 1)},
+		}
+
+		if format.HasMetadata() {
+			records[i].Metadata = shared_collection.Metadata{"lang": "en", "year": float64(2000 + i), "draft": i%2 == 0}
+			records[i].Text = "record " + string(rune('a'+i))
+		}
+	}
+
+	return records
+}
+
+func writeTestDataset(t *testing.T, path string, format Format, records []Record) {
+	t.Helper()
+
+	writer, err := Create(path, format)
+	if err != nil {
+		t.Fatalf("failed to create dataset: %v", err)
+	}
+
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatalf("failed to write key %d: %v", record.Key, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close dataset: %v", err)
+	}
+}
+
+// readTestDataset reads every record, the formats without keys are numbered from 10 like testRecords does
+func readTestDataset(t *testing.T, path string, format Format) []Record {
+	t.Helper()
+
+	reader, err := Open(path, format, ReaderOptions{FirstKey: 10})
+	if err != nil {
+		t.Fatalf("failed to open dataset: %v", err)
+	}
+	defer reader.Close()
+
+	var records []Record
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("failed to read record %d: %v", len(records), err)
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func assertTestRecords(t *testing.T, expected []Record, actual []Record) {
+	t.Helper()
+
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(actual))
+	}
+
+	for i := range expected {
+		// A record without metadata can be read back with either a nil or an empty map
+		if len(expected[i].Metadata) == 0 && len(actual[i].Metadata) == 0 {
+			actual[i].Metadata = expected[i].Metadata
+		}
+
+		if !reflect.DeepEqual(actual[i], expected[i]) {
+			t.Errorf("record %d: expected %+v, got %+v", i, expected[i], actual[i])
+		}
+	}
+}
+
+func TestDatasetRoundTrip(t *testing.T) {
+	for _, format := range testFormats {
+		for _, count := range []int{0, 1, 5} {
+			t.Run(fmt.Sprintf("%s/%d records", format, count), func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "dataset."+format.String())
+				records := testRecords(format, count)
+
+				writeTestDataset(t, path, format, records)
+				assertTestRecords(t, records, readTestDataset(t, path, format))
+			})
+		}
+	}
+}
+
+func TestImportExportRoundTrip(t *testing.T) {
+	for _, format := range testFormats {
+		t.Run(format.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			records := testRecords(format, 5)
+			writeTestDataset(t, filepath.Join(dir, "import."+format.String()), format, records)
+
+			config := shared_collection.NewCollectionConfig()
+			config.Dimensions = 4
+			config.MaxSize = 1 << 20
+			config.Metric = shared_collection.L2sq
+			config.VectorValidation = shared_collection.DefaultVectorValidation(shared_collection.L2sq)
+
+			reader, err := Open(filepath.Join(dir, "import."+format.String()), format, ReaderOptions{FirstKey: 10})
+			if err != nil {
+				t.Fatalf("failed to open dataset: %v", err)
+			}
+			progress, err := Import(reader, ImportOptions{
+				Config:    config,
+				BasePath:  filepath.Join(dir, "shard"),
+				Mode:      shared_collection.WriteModeInsert,
+				BatchSize: 2,
+			})
+			_ = reader.Close()
+			if err != nil {
+				t.Fatalf("failed to import dataset: %v", err)
+			}
+
+			if progress.Records != 5 || progress.Inserted != 5 || len(progress.Shards) != 1 {
+				t.Fatalf("expected 5 records inserted in 1 shard, got %+v", progress)
+			}
+
+			coll, err := shared_collection.NewCollection(config)
+			if err != nil {
+				t.Fatalf("failed to create collection: %v", err)
+			}
+			defer coll.Destroy()
+			if err := coll.Load(progress.Shards[0]); err != nil {
+				t.Fatalf("failed to load shard: %v", err)
+			}
+
+			path := filepath.Join(dir, "export."+format.String())
+			writer, err := Create(path, format)
+			if err != nil {
+				t.Fatalf("failed to create dataset: %v", err)
+			}
+			exported, err := Export(coll, writer, ExportOptions{IncludeMetadata: format.HasMetadata()})
+			if err != nil {
+				t.Fatalf("failed to export collection: %v", err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("failed to close dataset: %v", err)
+			}
+
+			if exported != 5 {
+				t.Errorf("expected 5 records exported, got %d", exported)
+			}
+			assertTestRecords(t, records, readTestDataset(t, path, format))
+		})
+	}
+}
+
+func TestVecsWriterRounding(t *testing.T) {
+	vector := shared_collection.Vector{-1.6, 0.4, 0.5, 254.6, 300}
+
+	tests := []struct {
+		format   Format
+		expected shared_collection.Vector
+	}{
+		{FormatFvecs, vector},
+		{FormatBvecs, shared_collection.Vector{0, 0, 1, 255, 255}},
+		{FormatIvecs, shared_collection.Vector{-2, 0, 1, 255, 300}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format.String(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dataset."+test.format.String())
+			writeTestDataset(t, path, test.format, []Record{{Key: 10, Vector: vector}})
+
+			expected := []Record{{Key: 10, Vector: test.expected}}
+			assertTestRecords(t, expected, readTestDataset(t, path, test.format))
+		})
+	}
+}
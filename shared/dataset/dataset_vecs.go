@@ -0,0 +1,141 @@
+package shared_dataset
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/danielealbano/svdb/shared/collection"
+	"io"
+	"math"
+	"os"
+)
+
+// vecsMaxDimensions bounds the dimensions read from a vector header to not allocate garbage on a corrupted file
+const vecsMaxDimensions = 1 << 16
+
+type vecsReader struct {
+	file   *os.File
+	reader *bufio.Reader
+	format Format
+	key    shared_collection.Key
+	buf    []byte
+}
+
+type vecsWriter struct {
+	file   *os.File
+	writer *bufio.Writer
+	format Format
+	buf    []byte
+}
+
+// vecsValueSize returns the size in bytes of a value of the format
+func vecsValueSize(format Format) int {
+	if format == FormatBvecs {
+		return 1
+	}
+
+	return 4
+}
+
+func newVecsReader(file *os.File, format Format, firstKey shared_collection.Key) *vecsReader {
+	return &vecsReader{
+		file:   file,
+		reader: bufio.NewReader(file),
+		format: format,
+		key:    firstKey,
+	}
+}
+
+func (r *vecsReader) Read() (Record, error) {
+	var dimensions int32
+
+	err := binary.Read(r.reader, binary.LittleEndian, &dimensions)
+	if err != nil {
+		// A file truncated in the middle of the header is reported as corrupted
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Record{}, fmt.Errorf("failed to read vector %d: %w", r.key, err)
+		}
+		return Record{}, err
+	}
+
+	if dimensions <= 0 || dimensions > vecsMaxDimensions {
+		return Record{}, fmt.Errorf("invalid dimensions %d of vector %d", dimensions, r.key)
+	}
+
+	size := int(dimensions) * vecsValueSize(r.format)
+	if cap(r.buf) < size {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+
+	_, err = io.ReadFull(r.reader, r.buf)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read vector %d: %w", r.key, io.ErrUnexpectedEOF)
+	}
+
+	vector := make(shared_collection.Vector, dimensions)
+	for i := range vector {
+		switch r.format {
+		case FormatFvecs:
+			vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(r.buf[i*4:]))
+		case FormatBvecs:
+			vector[i] = float32(r.buf[i])
+		case FormatIvecs:
+			vector[i] = float32(int32(binary.LittleEndian.Uint32(r.buf[i*4:])))
+		}
+	}
+
+	record := Record{Key: r.key, Vector: vector}
+	r.key++
+
+	return record, nil
+}
+
+func (r *vecsReader) Close() error {
+	return r.file.Close()
+}
+
+func newVecsWriter(file *os.File, format Format) *vecsWriter {
+	return &vecsWriter{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		format: format,
+	}
+}
+
+// Write writes the vector, the values are rounded to the nearest integer for ivecs and also clamped to 0-255 for bvecs
+func (w *vecsWriter) Write(record Record) error {
+	size := 4 + len(record.Vector)*vecsValueSize(w.format)
+	if cap(w.buf) < size {
+		w.buf = make([]byte, size)
+	}
+	w.buf = w.buf[:size]
+
+	binary.LittleEndian.PutUint32(w.buf, uint32(len(record.Vector)))
+	values := w.buf[4:]
+	for i, v := range record.Vector {
+		switch w.format {
+		case FormatFvecs:
+			binary.LittleEndian.PutUint32(values[i*4:], math.Float32bits(v))
+		case FormatBvecs:
+			values[i] = uint8(min(max(math.Round(float64(v)), 0), math.MaxUint8))
+		case FormatIvecs:
+			rounded := min(max(math.Round(float64(v)), math.MinInt32), math.MaxInt32)
+			binary.LittleEndian.PutUint32(values[i*4:], uint32(int32(rounded)))
+		}
+	}
+
+	_, err := w.writer.Write(w.buf)
+	return err
+}
+
+func (w *vecsWriter) Close() error {
+	err := w.writer.Flush()
+	if err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("failed to write dataset: %w", err)
+	}
+
+	return w.file.Close()
+}
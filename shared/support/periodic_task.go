@@ -0,0 +1,49 @@
+package shared_support
+
+import (
+	"sync"
+	"time"
+)
+
+// PeriodicTask runs a function every interval in its own goroutine until it's stopped, the runs never overlap
+type PeriodicTask struct {
+	interval time.Duration
+	run      func()
+	stop     chan struct{}
+	stopped  sync.WaitGroup
+}
+
+func NewPeriodicTask(interval time.Duration, run func()) *PeriodicTask {
+	return &PeriodicTask{
+		interval: interval,
+		run:      run,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (t *PeriodicTask) Start() {
+	t.stopped.Add(1)
+	go t.routine()
+}
+
+// Stop waits for the run in progress, if any, to complete
+func (t *PeriodicTask) Stop() {
+	close(t.stop)
+	t.stopped.Wait()
+}
+
+func (t *PeriodicTask) routine() {
+	defer t.stopped.Done()
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			t.run()
+		}
+	}
+}